@@ -3,68 +3,501 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"github.com/ReconfigureIO/goblin"
+	"github.com/ReconfigureIO/goblin/reverse"
 	"go/ast"
+	"go/build"
 	"go/parser"
+	"go/scanner"
 	"go/token"
+	"io"
 	"os"
+	"strings"
 )
 
 // Assuming you build with `make`, this variable will be filled in automatically
 // (leaning on -ldflags -X).
 var version string = "unspecified"
 
+var opts goblin.Options
+
+func perish(pos token.Position, typ string, reason string) {
+	fail(&goblin.Error{Type: typ, Info: reason, Position: pos})
+}
+
+func fail(err error) {
+	e, ok := err.(*goblin.Error)
+	if !ok {
+		e = &goblin.Error{Type: "internal_error", Info: err.Error(), Position: goblin.INVALID_POSITION}
+	}
+
+	if opts.Errors == goblin.PanicOnError {
+		panic(e.Error())
+	}
+
+	res, _ := json.Marshal(map[string]interface{}{
+		"error": e.Dump(),
+	})
+	os.Stderr.Write(res)
+	os.Exit(1)
+}
+
+func output(val interface{}, err error) {
+	errs, ok := err.(goblin.ErrorList)
+	if !ok && err != nil {
+		fail(err)
+	}
+
+	// in lenient mode we still have something worth printing.
+	res, _ := json.Marshal(val)
+	os.Stdout.Write(res)
+	report(errs)
+}
+
+// report writes out the errors collected in lenient mode, if there are any,
+// and exits.
+func report(errs goblin.ErrorList) {
+	if len(errs) == 0 {
+		return
+	}
+
+	res, _ := json.Marshal(map[string]interface{}{
+		"errors": errs.Dump(),
+	})
+	os.Stderr.Write(res)
+	os.Exit(1)
+}
+
+// fileList collects the paths given to repeated --file flags.
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *fileList) Set(p string) error {
+	*l = append(*l, p)
+	return nil
+}
+
+// readSource reads the file at path, or stdin if path is "-".
+func readSource(path string) []byte {
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		perish(goblin.TOPLEVEL_POSITION, "path_error", err.Error())
+	}
+
+	return src
+}
+
+// In lenient mode a syntax error isn't fatal: the parser still gives us a
+// tree, with BadExprs, BadStmts and BadDecls wherever it got confused, so we
+// dump that and report the syntax errors along with everything else, such
+// as type errors.
+func collect(syntaxErr error, others ...error) error {
+	errs := goblin.ErrorList{}
+	if list, ok := syntaxErr.(scanner.ErrorList); ok {
+		for _, v := range list {
+			errs = append(errs, &goblin.Error{Type: "syntax_error", Info: v.Msg, Position: v.Pos})
+		}
+	}
+
+	for _, err := range others {
+		if list, ok := err.(goblin.ErrorList); ok {
+			errs = append(errs, list...)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// typed returns a Dumper that annotates files, which make up the package
+// imported as path, with their types. Type errors are fatal unless we're in
+// lenient mode, in which case they're returned.
+func typed(fset *token.FileSet, path string, files []*ast.File) (*goblin.Dumper, error) {
+	checked, err := goblin.Check(fset, path, files)
+	if list, ok := err.(goblin.ErrorList); ok && opts.Errors != goblin.CollectErrors {
+		fail(list[0])
+	}
+
+	o := opts
+	o.Types = checked
+	return goblin.NewDumper(fset, o), err
+}
+
+// typedFiles type-checks the files given with --file. Files with the same
+// package clause are checked together, so that they can refer to one
+// another. It returns a Dumper for each file, along with each package's type
+// errors, which go with its first file so that they're only reported once.
+func typedFiles(fset *token.FileSet, files []*ast.File) ([]*goblin.Dumper, []error) {
+	var names []string
+	packages := map[string][]*ast.File{}
+	for _, f := range files {
+		name := f.Name.Name
+		if _, ok := packages[name]; !ok {
+			names = append(names, name)
+		}
+		packages[name] = append(packages[name], f)
+	}
+
+	dumpers := map[string]*goblin.Dumper{}
+	errs := map[string]error{}
+	for _, name := range names {
+		dumpers[name], errs[name] = typed(fset, name, packages[name])
+	}
+
+	fileDumpers := make([]*goblin.Dumper, len(files))
+	fileErrs := make([]error, len(files))
+	for i, f := range files {
+		name := f.Name.Name
+		fileDumpers[i] = dumpers[name]
+		fileErrs[i], errs[name] = errs[name], nil
+	}
+
+	return fileDumpers, fileErrs
+}
+
+// dumpModule streams one "package" document per line for every package
+// matched by patterns in the module containing the working directory. In
+// lenient mode, every package is dumped before the errors are reported.
+func dumpModule(patterns []string, tests goblin.TestFiles, ctxt *build.Context, types bool) {
+	m, err := goblin.FindModule(".")
+	if err != nil {
+		perish(goblin.TOPLEVEL_POSITION, "module_error", err.Error())
+	}
+
+	var pkgs []goblin.PackageDir
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matched, err := m.Match(ctxt, pattern)
+		if err != nil {
+			perish(goblin.TOPLEVEL_POSITION, "module_error", err.Error())
+		}
+
+		for _, p := range matched {
+			if !seen[p.Dir] {
+				seen[p.Dir] = true
+				pkgs = append(pkgs, p)
+			}
+		}
+	}
+
+	errs := goblin.ErrorList{}
+	out := json.NewEncoder(os.Stdout)
+	for _, p := range pkgs {
+		fset := token.NewFileSet()
+		pkg, files, syntaxErr := goblin.ParsePackage(ctxt, fset, p.Dir, tests, parser.ParseComments)
+		if _, ok := syntaxErr.(scanner.ErrorList); ok {
+			if opts.Errors != goblin.CollectErrors {
+				perish(goblin.INVALID_POSITION, "positionless_syntax_error", syntaxErr.Error())
+			}
+		} else if _, ok := syntaxErr.(*build.NoGoError); ok {
+			// e.g. a directory holding nothing but tests
+			continue
+		} else if syntaxErr != nil {
+			perish(goblin.TOPLEVEL_POSITION, "package_error", syntaxErr.Error())
+		}
+
+		if len(files) == 0 {
+			continue
+		}
+
+		pkg.ImportPath = p.ImportPath
+		if tests == goblin.ExternalTests {
+			pkg.ImportPath += "_test"
+		}
+
+		dumper := goblin.NewDumper(fset, opts)
+		var typeErr error
+		if types {
+			dumper, typeErr = typed(fset, pkg.ImportPath, files)
+		}
+
+		res, err := dumper.DumpPackage(pkg, files)
+		err = collect(syntaxErr, typeErr, err)
+		if list, ok := err.(goblin.ErrorList); ok {
+			errs = append(errs, list...)
+		} else if err != nil {
+			fail(err)
+		}
+
+		out.Encode(res)
+	}
+
+	report(errs)
+}
+
+// rebuild turns the output of any of the modes producing a single file, a
+// single node or a list of statements or declarations back into something
+// go/printer can print.
+func rebuild(dumped interface{}) (interface{}, error) {
+	switch dumped := dumped.(type) {
+	case map[string]interface{}:
+		return reverse.Node(dumped)
+
+	case []interface{}:
+		kind := "statement"
+		if len(dumped) > 0 {
+			if first, ok := dumped[0].(map[string]interface{}); ok {
+				kind, _ = first["kind"].(string)
+			}
+		}
+
+		switch kind {
+		case "statement":
+			return reverse.Stmts(dumped)
+		case "decl":
+			return reverse.Decls(dumped)
+		case "file", "package":
+			return nil, &goblin.Error{Type: "unexpected_node", Info: "--reverse prints a single file, so it has to be given one file at a time", Position: goblin.TOPLEVEL_POSITION}
+		}
+	}
+
+	return nil, &goblin.Error{Type: "json_error", Info: "expected a node, or a list of statements or declarations", Position: goblin.TOPLEVEL_POSITION}
+}
+
 func main() {
 	versionFlag := flag.Bool("v", false, "display goblin version")
 	builtinDumpFlag := flag.Bool("builtin-dump", false, "use go/ast to dump the file, not JSON")
 	panicFlag := flag.Bool("panic", false, "use panic() rather than JSON on error conditions")
-	fileFlag := flag.String("file", "", "file to parse")
+	lenientFlag := flag.Bool("lenient", false, "dump unsupported nodes as placeholders and report every error at the end")
+	legacyTypeAliasFlag := flag.Bool("legacy-type-alias", false, "dump single type declarations as \"type-alias\" decls")
+	rawPositionsFlag := flag.Bool("raw-positions", false, "ignore //line directives when reporting positions")
+	noPositionsFlag := flag.Bool("no-positions", false, "don't report positions at all")
+	rangesFlag := flag.Bool("ranges", false, "report the start and end position of every node")
+	noCommentsFlag := flag.Bool("no-comments", false, "leave comments out of the output")
+	var files fileList
+	flag.Var(&files, "file", "file to parse, or - for stdin; may be given more than once")
+	filenameFlag := flag.String("filename", "", "name to report in positions instead of the --file path")
+	ndjsonFlag := flag.Bool("ndjson", false, "write one JSON document per line rather than an array when given several files")
+	packageFlag := flag.String("package", "", "directory holding a package to parse")
+	testsFlag := flag.String("tests", "exclude", "what to do with _test.go files in --package mode: exclude, include or external")
+	tagsFlag := flag.String("tags", "", "comma-separated build tags to honour in --package mode")
 	stmtFlag := flag.String("stmt", "", "statement to parse")
+	stmtsFlag := flag.String("stmts", "", "list of statements to parse")
+	declFlag := flag.String("decl", "", "top-level declarations to parse")
 	exprFlag := flag.String("expr", "", "expression to parse")
+	reverseFlag := flag.Bool("reverse", false, "read goblin JSON from stdin and print it as Go")
+	schemaFlag := flag.Bool("schema", false, "print a JSON Schema describing goblin's output")
+	typesFlag := flag.Bool("types", false, "type-check the code and annotate expressions with their types (with --file, --package or patterns)")
+	formatVersionFlag := flag.Int("format-version", int(goblin.FormatV1), "version of the output format to produce: 1, or 2 for consistent kinds, a doc and comment on every declaration, and located comments in all-comments")
 
 	flag.Parse()
 	// Create the AST by parsing src.
 	fset := token.NewFileSet() // positions are relative to fset
 
+	if *panicFlag && *lenientFlag {
+		perish(goblin.TOPLEVEL_POSITION, "flag_error", "--panic and --lenient can't be used together")
+	}
+
 	if *panicFlag {
-		goblin.ShouldPanic = true
+		opts.Errors = goblin.PanicOnError
+	}
+
+	if *lenientFlag {
+		opts.Errors = goblin.CollectErrors
+	}
+
+	if *legacyTypeAliasFlag {
+		opts.Shape = goblin.LegacyTypeAliases
+	}
+
+	if *rawPositionsFlag {
+		opts.Positions = goblin.RawPositions
+	}
+
+	if *noPositionsFlag {
+		opts.Positions = goblin.NoPositions
+	}
+
+	if *rangesFlag {
+		opts.Ranges = true
+	}
+
+	if *noCommentsFlag {
+		opts.Comments = goblin.OmitComments
+	}
+
+	switch goblin.FormatVersion(*formatVersionFlag) {
+	case goblin.FormatV1, goblin.FormatV2:
+		opts.Format = goblin.FormatVersion(*formatVersionFlag)
+	default:
+		perish(goblin.TOPLEVEL_POSITION, "flag_error", fmt.Sprintf("unknown format version %d", *formatVersionFlag))
+	}
+
+	tests, ok := map[string]goblin.TestFiles{
+		"exclude":  goblin.ExcludeTests,
+		"include":  goblin.IncludeTests,
+		"external": goblin.ExternalTests,
+	}[*testsFlag]
+	if !ok {
+		perish(goblin.TOPLEVEL_POSITION, "flag_error", fmt.Sprintf("unknown --tests mode %q", *testsFlag))
+	}
+
+	ctxt := build.Default
+	if *tagsFlag != "" {
+		ctxt.BuildTags = strings.Split(*tagsFlag, ",")
+	}
+
+	dumper := goblin.NewDumper(fset, opts)
+
+	if *typesFlag && len(files) == 0 && *packageFlag == "" && flag.NArg() == 0 {
+		perish(goblin.TOPLEVEL_POSITION, "flag_error", "--types needs whole files: use --file, --package or package patterns")
 	}
 
 	if *versionFlag {
 		println(version)
 		return
-	} else if *fileFlag != "" {
-		file, err := os.Open(*fileFlag)
-		if err != nil {
-			goblin.Perish(goblin.TOPLEVEL_POSITION, "path_error", err.Error())
+	} else if *schemaFlag {
+		output(goblin.Schema(opts.Format), nil)
+	} else if *reverseFlag {
+		var dumped interface{}
+		if err := json.NewDecoder(os.Stdin).Decode(&dumped); err != nil {
+			perish(goblin.TOPLEVEL_POSITION, "json_error", err.Error())
 		}
-		info, err := file.Stat()
+
+		n, err := rebuild(dumped)
 		if err != nil {
-			goblin.Perish(goblin.TOPLEVEL_POSITION, "path_error", err.Error())
+			fail(err)
 		}
 
-		size := info.Size()
-		file.Close()
+		if err := reverse.Fprint(os.Stdout, n); err != nil {
+			perish(goblin.TOPLEVEL_POSITION, "printer_error", err.Error())
+		}
+	} else if len(files) > 0 {
+		if *filenameFlag != "" && len(files) > 1 {
+			perish(goblin.TOPLEVEL_POSITION, "flag_error", "--filename can only be used with a single --file")
+		}
 
-		fset.AddFile(*fileFlag, -1, int(size))
+		parsed := make([]*ast.File, len(files))
+		syntaxErrs := make([]error, len(files))
+		for i, path := range files {
+			name := path
+			if *filenameFlag != "" {
+				name = *filenameFlag
+			} else if path == "-" {
+				name = "stdin"
+			}
 
-		f, err := parser.ParseFile(fset, *fileFlag, nil, parser.ParseComments)
-		if err != nil {
-			goblin.Perish(goblin.INVALID_POSITION, "positionless_syntax_error", err.Error())
+			f, syntaxErr := parser.ParseFile(fset, name, readSource(path), parser.ParseComments)
+			if syntaxErr != nil && (opts.Errors != goblin.CollectErrors || f == nil) {
+				perish(goblin.INVALID_POSITION, "positionless_syntax_error", syntaxErr.Error())
+			}
+
+			if *builtinDumpFlag {
+				ast.Print(fset, f)
+				continue
+			}
+			parsed[i], syntaxErrs[i] = f, syntaxErr
 		}
 
 		if *builtinDumpFlag {
-			ast.Print(fset, f)
+			return
+		}
+
+		dumpers := make([]*goblin.Dumper, len(files))
+		typeErrs := make([]error, len(files))
+		if *typesFlag {
+			dumpers, typeErrs = typedFiles(fset, parsed)
 		} else {
-			val, _ := goblin.DumpFile(f, fset)
-			os.Stdout.Write(val)
+			for i := range dumpers {
+				dumpers[i] = dumper
+			}
+		}
+
+		var results []interface{}
+		errs := goblin.ErrorList{}
+		for i, f := range parsed {
+			res, err := dumpers[i].DumpFile(f)
+			err = collect(syntaxErrs[i], typeErrs[i], err)
+			if list, ok := err.(goblin.ErrorList); ok {
+				errs = append(errs, list...)
+			} else if err != nil {
+				fail(err)
+			}
+			results = append(results, res)
+		}
+
+		switch {
+		case *ndjsonFlag:
+			out := json.NewEncoder(os.Stdout)
+			for _, res := range results {
+				out.Encode(res)
+			}
+			report(errs)
+		case len(results) == 1:
+			output(results[0], errs)
+		default:
+			output(results, errs)
+		}
+	} else if *packageFlag != "" {
+		pkg, files, syntaxErr := goblin.ParsePackage(&ctxt, fset, *packageFlag, tests, parser.ParseComments)
+		if _, ok := syntaxErr.(scanner.ErrorList); ok {
+			if opts.Errors != goblin.CollectErrors {
+				perish(goblin.INVALID_POSITION, "positionless_syntax_error", syntaxErr.Error())
+			}
+		} else if syntaxErr != nil {
+			perish(goblin.TOPLEVEL_POSITION, "package_error", syntaxErr.Error())
 		}
+
+		var typeErr error
+		if *typesFlag {
+			dumper, typeErr = typed(fset, pkg.ImportPath, files)
+		}
+
+		res, err := dumper.DumpPackage(pkg, files)
+		output(res, collect(syntaxErr, typeErr, err))
 	} else if *exprFlag != "" {
-		val, _ := json.Marshal(goblin.TestExpr(*exprFlag))
-		os.Stdout.Write(val)
+		e, err := parser.ParseExprFrom(fset, "stdin", *exprFlag, 0)
+		if err != nil {
+			perish(goblin.INVALID_POSITION, "positionless_syntax_error", err.Error())
+		}
+
+		output(dumper.DumpExpr(e))
 	} else if *stmtFlag != "" {
-		val := goblin.TestStmt(*stmtFlag)
-		os.Stdout.Write(val)
+		stmts, origin, err := goblin.ParseStmts(fset, *stmtFlag)
+		if err != nil {
+			perish(goblin.INVALID_POSITION, "positionless_syntax_error", err.Error())
+		}
+
+		if len(stmts) != 1 {
+			perish(goblin.INVALID_POSITION, "positionless_syntax_error", fmt.Sprintf("expected a single statement, found %d (see --stmts)", len(stmts)))
+		}
+
+		dumper.SetOrigin(origin)
+		output(dumper.DumpStmt(stmts[0]))
+	} else if *stmtsFlag != "" {
+		stmts, origin, err := goblin.ParseStmts(fset, *stmtsFlag)
+		if err != nil {
+			perish(goblin.INVALID_POSITION, "positionless_syntax_error", err.Error())
+		}
+
+		dumper.SetOrigin(origin)
+		output(dumper.DumpStmts(stmts))
+	} else if *declFlag != "" {
+		decls, origin, err := goblin.ParseDecls(fset, *declFlag)
+		if err != nil {
+			perish(goblin.INVALID_POSITION, "positionless_syntax_error", err.Error())
+		}
+
+		dumper.SetOrigin(origin)
+		output(dumper.DumpDecls(decls))
+	} else if flag.NArg() > 0 {
+		dumpModule(flag.Args(), tests, &ctxt, *typesFlag)
 	} else {
 		flag.PrintDefaults()
 	}
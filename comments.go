@@ -0,0 +1,130 @@
+package goblin
+
+import (
+	"go/ast"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Each comment group in a file is attached to the node go/ast.CommentMap
+// associates it with, which is named by a JSON Pointer (RFC 6901) into the
+// file's dump: "/declarations/0/body/2" is the third statement of the first
+// declaration, and "" is the file itself. While a file is being dumped, d.nodes
+// remembers which map each located node was dumped as, so that the path can
+// be found once the dump is complete.
+
+// dumpComment dumps a single // or /* */ comment.
+func (d *Dumper) dumpComment(c *ast.Comment) map[string]interface{} {
+	style := "line"
+	if strings.HasPrefix(c.Text, "/*") {
+		style = "block"
+	}
+
+	return d.located(c, map[string]interface{}{
+		"kind": "comment",
+		"type": style,
+		"text": c.Text,
+		"end":  d.dumpPosition(c.End()),
+	})
+}
+
+// dumpAllComments dumps every comment group in f, given res, the dump of f
+// itself.
+func (d *Dumper) dumpAllComments(f *ast.File, res map[string]interface{}) []interface{} {
+	groups := []interface{}{}
+	if d.opts.Comments == OmitComments {
+		return groups
+	}
+
+	attached := map[*ast.CommentGroup]ast.Node{}
+	for n, list := range ast.NewCommentMap(d.fset, f, f.Comments) {
+		for _, g := range list {
+			attached[g] = n
+		}
+	}
+
+	paths := map[uintptr]string{}
+	pointers("", res, paths)
+	parents := map[ast.Node]ast.Node{}
+
+	for _, g := range f.Comments {
+		comments := make([]interface{}, len(g.List))
+		for i, c := range g.List {
+			comments[i] = d.dumpComment(c)
+		}
+
+		groups = append(groups, d.located(g, map[string]interface{}{
+			"kind":     "comment-group",
+			"comments": comments,
+			"end":      d.dumpPosition(g.End()),
+			"node":     d.nodePath(f, attached[g], paths, parents),
+		}))
+	}
+
+	return groups
+}
+
+// nodePath finds the path of the dump of n. Some nodes, such as function
+// bodies, aren't dumped as nodes of their own, so we go up to the closest
+// ancestor that was. Anything else is attached to the file.
+func (d *Dumper) nodePath(f *ast.File, n ast.Node, paths map[uintptr]string, parents map[ast.Node]ast.Node) string {
+	for n != nil {
+		if m, ok := d.nodes[n]; ok {
+			if p, ok := paths[reflect.ValueOf(m).Pointer()]; ok {
+				return p
+			}
+		}
+
+		if len(parents) == 0 {
+			var stack []ast.Node
+			ast.Inspect(f, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				if len(stack) > 0 {
+					parents[n] = stack[len(stack)-1]
+				}
+				stack = append(stack, n)
+				return true
+			})
+		}
+		n = parents[n]
+	}
+
+	return ""
+}
+
+// pointers records the path of every map within v, which is found at path.
+// A map that turns up twice keeps the first path, visiting keys in order.
+func pointers(path string, v interface{}, paths map[uintptr]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		p := reflect.ValueOf(v).Pointer()
+		if _, ok := paths[p]; ok {
+			return
+		}
+		paths[p] = path
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			pointers(path+"/"+k, v[k], paths)
+		}
+
+	case []interface{}:
+		for i, e := range v {
+			pointers(path+"/"+strconv.Itoa(i), e, paths)
+		}
+
+	case []map[string]interface{}:
+		for i, e := range v {
+			pointers(path+"/"+strconv.Itoa(i), e, paths)
+		}
+	}
+}
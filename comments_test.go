@@ -0,0 +1,66 @@
+package goblin
+
+import (
+	"go/parser"
+	"reflect"
+	"testing"
+)
+
+const commentedSource = `// Package p is documented.
+package p
+
+// F is documented.
+func F() {
+	x := 1 // x is one.
+	/* block */
+	_ = x
+}
+
+func (t /* receiver */ T) M() {}
+`
+
+func TestAllComments(t *testing.T) {
+	res := dumpSource(t, "commented.go", commentedSource, parser.ParseComments, Options{Format: FormatV2})
+	groups := res["all-comments"].([]interface{})
+
+	expected := []struct {
+		node, style, text string
+		line, end         float64
+	}{
+		{"", "line", "// Package p is documented.", 1, 28},
+		{"/declarations/0", "line", "// F is documented.", 4, 20},
+		{"/declarations/0/body/0", "line", "// x is one.", 6, 21},
+		{"/declarations/0/body/1", "block", "/* block */", 7, 13},
+		{"/declarations/1/receiver/names/0", "block", "/* receiver */", 11, 23},
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("Dumped %d comment groups, not %d", len(groups), len(expected))
+	}
+
+	for i, e := range expected {
+		g := groups[i].(map[string]interface{})
+		if g["kind"] != "comment-group" || g["node"] != e.node {
+			t.Errorf("Attached %q to %v, not %q", e.text, g["node"], e.node)
+		}
+
+		c := g["comments"].([]interface{})[0].(map[string]interface{})
+		position := c["position"].(map[string]interface{})
+		end := c["end"].(map[string]interface{})
+		if c["kind"] != "comment" || c["type"] != e.style || c["text"] != e.text || position["line"] != e.line || end["column"] != e.end {
+			t.Errorf("Dumped %q as %v", e.text, c)
+		}
+	}
+
+	res = dumpSource(t, "commented.go", commentedSource, parser.ParseComments, Options{Format: FormatV2, Comments: OmitComments})
+	if len(res["all-comments"].([]interface{})) != 0 {
+		t.Error("Dumped comments despite OmitComments")
+	}
+}
+
+func TestAllCommentsV1(t *testing.T) {
+	res := dumpSource(t, "commented.go", commentedSource, parser.ParseComments, Options{Format: FormatV1})
+	groups := res["all-comments"].([][]string)
+	if !reflect.DeepEqual(groups[1], []string{"// F is documented."}) {
+		t.Error("Didn't dump v1 comment groups as lists of text")
+	}
+}
@@ -0,0 +1,143 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"strings"
+)
+
+// Directives are only recognised by the toolchain at the start of a line, so
+// we do the same. Besides the //tool:name form, cgo's //export and //extern
+// and the compiler's //line don't name a tool.
+var toollessDirectives = []string{"export", "extern", "line"}
+
+// dumpDirective dumps c if it's a directive, and returns nil otherwise.
+func (d *Dumper) dumpDirective(c *ast.Comment) map[string]interface{} {
+	if d.fset.PositionFor(c.Slash, false).Column != 1 {
+		return nil
+	}
+
+	directive, ok := ast.ParseDirective(c.Slash, c.Text)
+	if !ok {
+		for _, name := range toollessDirectives {
+			if args, found := strings.CutPrefix(c.Text, "//"+name+" "); found {
+				directive = ast.Directive{Name: name, Args: strings.TrimSpace(args)}
+				ok = true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	return d.located(c, map[string]interface{}{
+		"kind":      "directive",
+		"tool":      directive.Tool,
+		"name":      directive.Name,
+		"arguments": directive.Args,
+	})
+}
+
+// dumpDirectives dumps every directive in groups.
+func (d *Dumper) dumpDirectives(groups []*ast.CommentGroup) []interface{} {
+	res := []interface{}{}
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		for _, c := range g.List {
+			if directive := d.dumpDirective(c); directive != nil {
+				res = append(res, directive)
+			}
+		}
+	}
+
+	return res
+}
+
+// dumpBuildConstraint finds f's build constraint and dumps it as a tree of
+// "constraint" nodes, returning nil if there isn't one. As with the go tool, a
+// //go:build line takes precedence over // +build lines, which are otherwise
+// all required to hold. Only comments above the package clause count.
+func (d *Dumper) dumpBuildConstraint(f *ast.File) map[string]interface{} {
+	var plusBuild []*ast.Comment
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package {
+			break
+		}
+
+		for _, c := range g.List {
+			switch {
+			case constraint.IsGoBuild(c.Text):
+				return d.parseConstraints(c)
+			case constraint.IsPlusBuild(c.Text):
+				plusBuild = append(plusBuild, c)
+			}
+		}
+	}
+
+	if len(plusBuild) == 0 {
+		return nil
+	}
+
+	return d.parseConstraints(plusBuild...)
+}
+
+// parseConstraints parses build constraint lines that must all hold. Every
+// node is positioned at the first line, as build constraints don't record
+// where they came from.
+func (d *Dumper) parseConstraints(lines ...*ast.Comment) map[string]interface{} {
+	var res constraint.Expr
+	for _, c := range lines {
+		x, err := constraint.Parse(c.Text)
+		if err != nil {
+			d.report(d.position(c.Pos()), "invalid_build_constraint", err.Error())
+			return nil
+		}
+
+		if res == nil {
+			res = x
+		} else {
+			res = &constraint.AndExpr{X: res, Y: x}
+		}
+	}
+
+	return d.dumpConstraint(lines[0], res)
+}
+
+func (d *Dumper) dumpConstraint(c *ast.Comment, x constraint.Expr) map[string]interface{} {
+	switch x := x.(type) {
+	case *constraint.AndExpr:
+		return d.located(c, map[string]interface{}{
+			"kind":  "constraint",
+			"type":  "and",
+			"left":  d.dumpConstraint(c, x.X),
+			"right": d.dumpConstraint(c, x.Y),
+		})
+
+	case *constraint.OrExpr:
+		return d.located(c, map[string]interface{}{
+			"kind":  "constraint",
+			"type":  "or",
+			"left":  d.dumpConstraint(c, x.X),
+			"right": d.dumpConstraint(c, x.Y),
+		})
+
+	case *constraint.NotExpr:
+		return d.located(c, map[string]interface{}{
+			"kind":   "constraint",
+			"type":   "not",
+			"target": d.dumpConstraint(c, x.X),
+		})
+
+	case *constraint.TagExpr:
+		return d.located(c, map[string]interface{}{
+			"kind": "constraint",
+			"type": "tag",
+			"tag":  x.Tag,
+		})
+	}
+
+	panic("unreachable")
+}
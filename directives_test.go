@@ -0,0 +1,84 @@
+package goblin
+
+import (
+	"go/parser"
+	"testing"
+)
+
+const directiveSource = `//go:build linux && (amd64 || !cgo)
+
+package p
+
+import "embed"
+
+//go:generate stringer -type T
+
+//go:embed static
+var static embed.FS
+
+// F is exported to C.
+//
+//export F
+//go:noinline
+func F() {
+	x := 1 //go:noinline
+	_ = x
+}
+`
+
+func TestDirectives(t *testing.T) {
+	res := dumpSource(t, "directives.go", directiveSource, parser.ParseComments, Options{Format: FormatV2})
+
+	expected := [][3]string{
+		{"go", "build", "linux && (amd64 || !cgo)"},
+		{"go", "generate", "stringer -type T"},
+		{"go", "embed", "static"},
+		{"", "export", "F"},
+		{"go", "noinline", ""},
+	}
+	directives := res["directives"].([]interface{})
+	if len(directives) != len(expected) {
+		t.Fatalf("Dumped %d directives, not %d", len(directives), len(expected))
+	}
+	for i, e := range expected {
+		d := directives[i].(map[string]interface{})
+		if d["kind"] != "directive" || d["tool"] != e[0] || d["name"] != e[1] || d["arguments"] != e[2] {
+			t.Errorf("Dumped %v rather than %v", d, e)
+		}
+	}
+
+	fn := res["declarations"].([]interface{})[2].(map[string]interface{})
+	if len(fn["directives"].([]interface{})) != 2 {
+		t.Error("Didn't attach a function's directives to it")
+	}
+
+	// linux && (amd64 || !cgo)
+	and := res["build-constraint"].(map[string]interface{})
+	linux := and["left"].(map[string]interface{})
+	or := and["right"].(map[string]interface{})
+	not := or["right"].(map[string]interface{})
+	cgo := not["target"].(map[string]interface{})
+	if and["type"] != "and" || linux["tag"] != "linux" || or["type"] != "or" || not["type"] != "not" || cgo["tag"] != "cgo" {
+		t.Error("Didn't parse the build constraint")
+	}
+}
+
+func TestPlusBuild(t *testing.T) {
+	res := dumpSource(t, "directives.go", "// +build linux darwin\n// +build !cgo\n\npackage p\n", parser.ParseComments, Options{Format: FormatV2})
+	and := res["build-constraint"].(map[string]interface{})
+	if and["type"] != "and" || and["left"].(map[string]interface{})["type"] != "or" {
+		t.Error("Didn't combine // +build lines")
+	}
+
+	res = dumpSource(t, "directives.go", "package p\n", parser.ParseComments, Options{Format: FormatV2})
+	if _, ok := res["build-constraint"]; ok {
+		t.Error("Dumped a build constraint for a file without one")
+	}
+
+	// Directives and build constraints are new keys, so format v1 has
+	// them too.
+	res = dumpSource(t, "directives.go", directiveSource, parser.ParseComments, Options{Format: FormatV1})
+	if len(res["directives"].([]interface{})) != 5 || res["build-constraint"] == nil {
+		t.Error("Left directives out of format v1")
+	}
+}
@@ -2,11 +2,14 @@ package goblin
 
 import (
 	"encoding/json"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -21,27 +24,145 @@ and perhaps a corresponding NilAllowed? mumble mumble million-dollar mistake mum
 
 */
 
-var ShouldPanic bool = false
 var TOPLEVEL_POSITION token.Position = token.Position{Filename: "toplevel", Offset: -1, Line: -1, Column: -1}
 var INVALID_POSITION token.Position = token.Position{Filename: "unspecified", Offset: -1, Line: -1, Column: -1}
 
+// Error is returned by a Dumper when it meets a node it can't make sense of.
+// Type is a machine-readable category such as "unexpected_node", and Info
+// says what exactly went wrong.
+type Error struct {
+	Type     string
+	Info     string
+	Position token.Position
+}
+
+func (e *Error) Error() string {
+	return e.Position.String() + ": " + e.Info
+}
+
+func (e *Error) Dump() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     e.Type,
+		"info":     e.Info,
+		"position": DumpPosition(e.Position),
+	}
+}
+
+// ErrorList is returned by a Dumper that collects errors rather than stopping
+// at the first one, in the order the errors were found.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return l[0].Error() + " (and " + strconv.Itoa(len(l)-1) + " more errors)"
+}
+
+func (l ErrorList) Dump() []interface{} {
+	result := make([]interface{}, len(l))
+	for i, v := range l {
+		result[i] = v.Dump()
+	}
+
+	return result
+}
+
+// A Dumper turns the nodes of a single token.FileSet into JSON-friendly maps.
+// Its exported methods never exit or panic on malformed input (unless its
+// options ask for PanicOnError); they return an *Error instead, or an
+// ErrorList alongside their output when its options ask for CollectErrors.
+// A Dumper must not be used by several goroutines at once.
+type Dumper struct {
+	fset   *token.FileSet
+	opts   Options
+	errors ErrorList
+	// origin is where positions are counted from, if SetOrigin was called.
+	origin token.Pos
+	// nodes maps each node of the file being dumped to its dump, for
+	// dumpAllComments.
+	nodes map[ast.Node]map[string]interface{}
+	// packageScope holds the names declared at the top level of the package
+	// being dumped, for builtin.
+	packageScope map[string]bool
+	// annotated maps each expression to the dump annotate last gave its
+	// types to, when Options.Types is set.
+	annotated map[ast.Expr]map[string]interface{}
+}
+
+func NewDumper(fset *token.FileSet, opts Options) *Dumper {
+	return &Dumper{fset: fset, opts: opts}
+}
+
 // Golang has no way to mark a function as noreturn, so if the compiler
 // complains about missing return values, put a panic("unreachable") after it.
+// The panic raised here is turned back into an error by Dumper.recover.
 
-func Perish(pos token.Position, typ string, reason string) {
-	if ShouldPanic {
-		panic(pos.String() + ": " + reason)
-	} else {
-		res, _ := json.Marshal(map[string]interface{}{
-			"error": map[string]interface{}{
-				"type":     typ,
-				"info":     reason,
-				"position": DumpPosition(pos),
-			},
-		})
-		os.Stderr.Write(res)
+func (d *Dumper) perish(pos token.Position, typ string, reason string) {
+	panic(&Error{Type: typ, Info: reason, Position: pos})
+}
+
+// report records an error if the Dumper is collecting them, and perishes
+// otherwise.
+func (d *Dumper) report(pos token.Position, typ string, reason string) {
+	if d.opts.Errors != CollectErrors {
+		d.perish(pos, typ, reason)
 	}
-	os.Exit(1)
+
+	d.errors = append(d.errors, &Error{Type: typ, Info: reason, Position: pos})
+}
+
+// unsupported reports a node that can't be dumped. If the Dumper is collecting
+// errors, the node is replaced by a placeholder that records its Go type.
+func (d *Dumper) unsupported(n ast.Node, pos token.Position, typ string, reason string) map[string]interface{} {
+	d.report(pos, typ, reason)
+
+	return d.located(n, map[string]interface{}{
+		"kind": "unsupported",
+		"type": reflect.TypeOf(n).String(),
+	})
+}
+
+// collected returns the errors found since the last call to begin, if any.
+func (d *Dumper) collected() error {
+	if len(d.errors) == 0 {
+		return nil
+	}
+
+	return d.errors
+}
+
+func (d *Dumper) begin() {
+	d.errors = nil
+	if d.opts.Types != nil {
+		d.annotated = map[ast.Expr]map[string]interface{}{}
+	}
+}
+
+func (d *Dumper) recover(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	e, ok := r.(*Error)
+	if !ok {
+		// Anything else is a bug in goblin, but it's still up to the caller
+		// whether a bug is worth crashing over.
+		if d.opts.Errors == PanicOnError {
+			panic(r)
+		}
+		e = &Error{Type: "internal_error", Info: fmt.Sprint(r), Position: INVALID_POSITION}
+	}
+
+	if d.opts.Errors == PanicOnError {
+		panic(e.Error())
+	}
+
+	*err = e
 }
 
 func DumpPosition(p token.Position) map[string]interface{} {
@@ -55,162 +176,273 @@ func DumpPosition(p token.Position) map[string]interface{} {
 	}
 }
 
-func DumpIdent(i *ast.Ident, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpIdent(i *ast.Ident) map[string]interface{} {
 	if i == nil {
 		return nil
 	}
 
-	asLiteral := map[string]interface{}{
-		"kind":     "literal",
-		"type":     "BOOL",
-		"position": DumpPosition(fset.Position(i.Pos())),
-	}
-
 	switch i.Name {
-	case "true":
-		asLiteral["value"] = "true"
-		return asLiteral
-
-	case "false":
-		asLiteral["value"] = "false"
-		return asLiteral
+	case "true", "false":
+		return d.located(i, map[string]interface{}{
+			"kind":  "literal",
+			"type":  "BOOL",
+			"value": i.Name,
+		})
 
 	case "iota":
-		asLiteral["type"] = "IOTA"
-		return asLiteral
-
+		return d.located(i, map[string]interface{}{
+			"kind": "literal",
+			"type": "IOTA",
+		})
 	}
 
-	return map[string]interface{}{
-		"kind":     "ident",
-		"value":    i.Name,
-		"position": DumpPosition(fset.Position(i.Pos())),
-	}
+	return d.located(i, map[string]interface{}{
+		"kind":  "ident",
+		"value": i.Name,
+	})
 }
 
-func DumpArray(a *ast.ArrayType, fset *token.FileSet) map[string]interface{} {
-	return map[string]interface{}{
-		"kind":     "array",
-		"length":   DumpExpr(a.Len, fset),
-		"element":  DumpExprAsType(a.Elt, fset),
-		"position": DumpPosition(fset.Position(a.Pos())),
-	}
+func (d *Dumper) dumpArray(a *ast.ArrayType) map[string]interface{} {
+	return d.located(a, map[string]interface{}{
+		"kind":    "array",
+		"length":  d.dumpExpr(a.Len),
+		"element": d.dumpExprAsType(a.Elt),
+	})
 }
 
-func AttemptExprAsType(e ast.Expr, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) attemptExprAsType(e ast.Expr) map[string]interface{} {
 	if e == nil {
 		return nil
 	}
 
 	if n, ok := e.(*ast.ParenExpr); ok {
-		return AttemptExprAsType(n.X, fset)
+		return d.attemptExprAsType(n.X)
 	}
 
 	if n, ok := e.(*ast.Ident); ok {
-		return map[string]interface{}{
-			"kind":     "type",
-			"type":     "identifier",
-			"value":    DumpIdent(n, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":  "type",
+			"type":  "identifier",
+			"value": d.dumpIdent(n),
+		})
 	}
 
 	if n, ok := e.(*ast.SelectorExpr); ok {
-		lhs := DumpExpr(n.X, fset)
-
-		if lhs["type"] == "identifier" && lhs["qualifier"] == nil {
-			return map[string]interface{}{
+		if x, ok := n.X.(*ast.Ident); ok {
+			return d.located(e, map[string]interface{}{
 				"kind":      "type",
 				"type":      "identifier",
-				"qualifier": lhs["value"],
-				"value":     DumpIdent(n.Sel, fset),
-				"position":  DumpPosition(fset.Position(e.Pos())),
-			}
+				"qualifier": d.dumpIdent(x),
+				"value":     d.dumpIdent(n.Sel),
+			})
 		}
 	}
 
+	if n, ok := e.(*ast.IndexExpr); ok {
+		return d.attemptInstantiation(n, n.X, []ast.Expr{n.Index})
+	}
+
+	// the type of a variadic parameter
+	if n, ok := e.(*ast.Ellipsis); ok && n.Elt != nil {
+		return d.located(e, map[string]interface{}{
+			"kind":  "type",
+			"type":  "ellipsis",
+			"value": d.dumpExprAsType(n.Elt),
+		})
+	}
+
+	if n, ok := e.(*ast.IndexListExpr); ok {
+		return d.attemptInstantiation(n, n.X, n.Indices)
+	}
+
 	if n, ok := e.(*ast.ArrayType); ok {
 		if n.Len == nil {
-			return map[string]interface{}{
-				"kind":     "type",
-				"type":     "slice",
-				"element":  DumpExprAsType(n.Elt, fset),
-				"position": DumpPosition(fset.Position(e.Pos())),
-			}
+			return d.located(e, map[string]interface{}{
+				"kind":    "type",
+				"type":    "slice",
+				"element": d.dumpExprAsType(n.Elt),
+			})
 		} else {
-			return map[string]interface{}{
-				"kind":     "type",
-				"type":     "array",
-				"element":  DumpExprAsType(n.Elt, fset),
-				"length":   DumpExpr(n.Len, fset),
-				"position": DumpPosition(fset.Position(e.Pos())),
-			}
+			return d.located(e, map[string]interface{}{
+				"kind":    "type",
+				"type":    "array",
+				"element": d.dumpExprAsType(n.Elt),
+				"length":  d.dumpExpr(n.Len),
+			})
 		}
 	}
 
 	if n, ok := e.(*ast.StarExpr); ok {
-		return map[string]interface{}{
+		return d.located(e, map[string]interface{}{
 			"kind":      "type",
 			"type":      "pointer",
-			"contained": DumpExprAsType(n.X, fset),
-			"position":  DumpPosition(fset.Position(e.Pos())),
-		}
+			"contained": d.dumpExprAsType(n.X),
+		})
 	}
 
 	if n, ok := e.(*ast.InterfaceType); ok {
-		return map[string]interface{}{
-			"kind":       "type",
-			"type":       "interface",
-			"incomplete": n.Incomplete,
-			"methods":    DumpFields(n.Methods, fset),
-			"position":   DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.dumpInterfaceType(n)
+	}
+
+	if n, ok := e.(*ast.BinaryExpr); ok && n.Op == token.OR {
+		return d.attemptUnion(n)
+	}
+
+	if n, ok := e.(*ast.UnaryExpr); ok && n.Op == token.TILDE {
+		return d.attemptUnion(n)
 	}
 
 	if n, ok := e.(*ast.MapType); ok {
-		return map[string]interface{}{
-			"kind":     "type",
-			"type":     "map",
-			"key":      DumpExprAsType(n.Key, fset),
-			"value":    DumpExprAsType(n.Value, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":  "type",
+			"type":  "map",
+			"key":   d.dumpExprAsType(n.Key),
+			"value": d.dumpExprAsType(n.Value),
+		})
 	}
 
 	if n, ok := e.(*ast.ChanType); ok {
-		return map[string]interface{}{
+		return d.located(e, map[string]interface{}{
 			"kind":      "type",
 			"type":      "chan",
-			"direction": DumpChanDir(n.Dir),
-			"value":     DumpExprAsType(n.Value, fset),
-			"position":  DumpPosition(fset.Position(e.Pos())),
-		}
+			"direction": d.dumpChanDir(n.Dir),
+			"value":     d.dumpExprAsType(n.Value),
+		})
 	}
 
 	if n, ok := e.(*ast.StructType); ok {
-		return map[string]interface{}{
-			"kind":     "type",
-			"type":     "struct",
-			"fields":   DumpFields(n.Fields, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":   "type",
+			"type":   "struct",
+			"fields": d.dumpFields(n.Fields),
+		})
 	}
 
 	if n, ok := e.(*ast.FuncType); ok {
-		return map[string]interface{}{
-			"kind":     "type",
-			"type":     "function",
-			"params":   DumpFields(n.Params, fset),
-			"results":  DumpFields(n.Results, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":    "type",
+			"type":    "function",
+			"params":  d.dumpFields(n.Params),
+			"results": d.dumpFields(n.Results),
+		})
 	}
 
 	return nil
 }
 
-func DumpExprAsType(e ast.Expr, fset *token.FileSet) map[string]interface{} {
-	result := AttemptExprAsType(e, fset)
+// In a type position, `List[int]` and `Map[K, V]` are instantiations of a
+// generic type. Outside of one, an IndexExpr may just as well be indexing a
+// slice or a map, so we only treat it as an instantiation if every argument
+// looks like a type.
+func (d *Dumper) attemptInstantiation(e ast.Expr, generic ast.Expr, args []ast.Expr) map[string]interface{} {
+	base := d.attemptExprAsType(generic)
+	if base == nil {
+		return nil
+	}
+
+	types := make([]interface{}, len(args))
+	for i, v := range args {
+		arg := d.attemptExprAsType(v)
+		if arg == nil {
+			return nil
+		}
+		types[i] = arg
+	}
+
+	return d.located(e, map[string]interface{}{
+		"kind":      "type",
+		"type":      "instantiation",
+		"generic":   base,
+		"arguments": types,
+	})
+}
+
+// Interfaces can contain three sorts of elements: methods, embedded types
+// (usually other interfaces), and, when used as constraints, unions of type
+// terms such as `~int | ~float64`. We dump each sort into its own list.
+func (d *Dumper) dumpInterfaceType(n *ast.InterfaceType) map[string]interface{} {
+	methods := []interface{}{}
+	embedded := []interface{}{}
+	typeSet := []interface{}{}
+
+	if n.Methods != nil {
+		for _, v := range n.Methods.List {
+			if len(v.Names) > 0 {
+				methods = append(methods, d.dumpField(v))
+				continue
+			}
+
+			switch t := v.Type.(type) {
+			case *ast.BinaryExpr:
+				typeSet = append(typeSet, d.dumpExprAsType(t))
+			case *ast.UnaryExpr:
+				typeSet = append(typeSet, d.dumpExprAsType(t))
+			default:
+				embedded = append(embedded, d.dumpExprAsType(t))
+			}
+		}
+	}
+
+	return d.located(n, map[string]interface{}{
+		"kind":       "type",
+		"type":       "interface",
+		"incomplete": n.Incomplete,
+		"methods":    methods,
+		"embedded":   embedded,
+		"type-set":   typeSet,
+	})
+}
+
+// A union is dumped as a flat list of terms, even though the parser nests
+// them as left-associative BinaryExprs. A lone `~int` is a union of one term.
+func (d *Dumper) attemptUnion(e ast.Expr) map[string]interface{} {
+	var terms []interface{}
+	for _, v := range UnionTerms(e) {
+		term := d.attemptUnionTerm(v)
+		if term == nil {
+			return nil
+		}
+		terms = append(terms, term)
+	}
+
+	return d.located(e, map[string]interface{}{
+		"kind":  "type",
+		"type":  "union",
+		"terms": terms,
+	})
+}
+
+func UnionTerms(e ast.Expr) []ast.Expr {
+	if n, ok := e.(*ast.BinaryExpr); ok && n.Op == token.OR {
+		return append(UnionTerms(n.X), UnionTerms(n.Y)...)
+	}
+
+	return []ast.Expr{e}
+}
+
+func (d *Dumper) attemptUnionTerm(e ast.Expr) map[string]interface{} {
+	tilde := false
+	inner := e
+	if n, ok := e.(*ast.UnaryExpr); ok && n.Op == token.TILDE {
+		tilde = true
+		inner = n.X
+	}
+
+	value := d.attemptExprAsType(inner)
+	if value == nil {
+		return nil
+	}
+
+	return d.located(e, map[string]interface{}{
+		"kind":  "type",
+		"type":  "term",
+		"tilde": tilde,
+		"value": value,
+	})
+}
+
+func (d *Dumper) dumpExprAsType(e ast.Expr) map[string]interface{} {
+	result := d.attemptExprAsType(e)
 
 	if result != nil {
 		return result
@@ -218,13 +450,12 @@ func DumpExprAsType(e ast.Expr, fset *token.FileSet) map[string]interface{} {
 
 	// bail out
 	gotten := reflect.TypeOf(e).String()
-	pos := fset.PositionFor(e.Pos(), true)
-	Perish(pos, "unrecognized_type", gotten)
-	panic("unreachable")
+	pos := d.position(e.Pos())
+	return d.unsupported(e, pos, "unrecognized_type", gotten)
 }
 
-func DumpChanDir(d ast.ChanDir) string {
-	switch d {
+func (d *Dumper) dumpChanDir(dir ast.ChanDir) string {
+	switch dir {
 	case ast.SEND:
 		return "send"
 
@@ -235,57 +466,56 @@ func DumpChanDir(d ast.ChanDir) string {
 		return "both"
 	}
 
-	Perish(INVALID_POSITION, "internal_error", string(d))
-	panic("unreachable")
+	d.report(INVALID_POSITION, "internal_error", strconv.Itoa(int(dir)))
+	return "unsupported"
 }
 
-func DumpExpr(e ast.Expr, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpExpr(e ast.Expr) map[string]interface{} {
 	if e == nil {
 		return nil
 	}
 
 	if _, ok := e.(*ast.ArrayType); ok {
-		return DumpExprAsType(e, fset)
+		return d.dumpExprAsType(e)
 	}
 
 	if n, ok := e.(*ast.Ident); ok {
 
-		val := DumpIdent(n, fset)
+		val := d.dumpIdent(n)
 
-		if val["type"] == "BOOL" {
+		if val["type"] == "BOOL" && d.format() == FormatV1 {
 			return val
 		}
 
-		return map[string]interface{}{
-			"kind":     "expression",
-			"type":     "identifier",
-			"value":    val,
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":  "expression",
+			"type":  "identifier",
+			"value": val,
+		})
 	}
 
 	if n, ok := e.(*ast.Ellipsis); ok {
-		return map[string]interface{}{
+		return d.located(e, map[string]interface{}{
 			"type":  "ellipsis",
 			"kind":  "type",
-			"value": DumpExpr(n.Elt, fset),
-		}
+			"value": d.dumpExpr(n.Elt),
+		})
 	}
 
 	// is this the right place??
 	if n, ok := e.(*ast.FuncLit); ok {
-		return map[string]interface{}{
-			"kind":     "literal",
-			"type":     "function",
-			"params":   DumpFields(n.Type.Params, fset),
-			"results":  DumpFields(n.Type.Results, fset),
-			"body":     DumpBlock(n.Body, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":        "literal",
+			"type":        "function",
+			"type-params": d.dumpFields(n.Type.TypeParams),
+			"params":      d.dumpFields(n.Type.Params),
+			"results":     d.dumpFields(n.Type.Results),
+			"body":        d.dumpBlock(n.Body),
+		})
 	}
 
 	if n, ok := e.(*ast.BasicLit); ok {
-		return DumpBasicLit(n, fset)
+		return d.dumpBasicLit(n)
 	}
 
 	if n, ok := e.(*ast.CompositeLit); ok {
@@ -297,164 +527,219 @@ func DumpExpr(e ast.Expr, fset *token.FileSet) map[string]interface{} {
 		var declared map[string]interface{} = nil
 
 		if n.Type != nil {
-			declared = DumpExprAsType(n.Type, fset)
+			declared = d.dumpExprAsType(n.Type)
 		}
 
-		return map[string]interface{}{
+		return d.located(e, map[string]interface{}{
 			"kind":     "literal",
 			"type":     "composite",
 			"declared": declared,
-			"values":   DumpExprs(n.Elts, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+			"values":   d.dumpExprs(n.Elts),
+		})
 	}
 
 	if n, ok := e.(*ast.BinaryExpr); ok {
-		return DumpBinaryExpr(n, fset)
+		return d.dumpBinaryExpr(n)
 	}
 
 	if n, ok := e.(*ast.IndexExpr); ok {
-		return map[string]interface{}{
-			"kind":     "expression",
-			"type":     "index",
-			"target":   DumpExpr(n.X, fset),
-			"index":    DumpExpr(n.Index, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
+		// `f[int]` instantiates a generic function, but looks just like
+		// indexing a slice or a map. Only type information tells them apart.
+		if isType, _ := d.isType(n.Index); isType {
+			return d.located(e, map[string]interface{}{
+				"kind":      "expression",
+				"type":      "instantiation",
+				"generic":   d.dumpExpr(n.X),
+				"arguments": d.dumpExprsAsType([]ast.Expr{n.Index}),
+			})
 		}
+
+		return d.located(e, map[string]interface{}{
+			"kind":   "expression",
+			"type":   "index",
+			"target": d.dumpExpr(n.X),
+			"index":  d.dumpExpr(n.Index),
+		})
+	}
+
+	if n, ok := e.(*ast.IndexListExpr); ok {
+		return d.located(e, map[string]interface{}{
+			"kind":      "expression",
+			"type":      "instantiation",
+			"generic":   d.dumpExpr(n.X),
+			"arguments": d.dumpExprsAsType(n.Indices),
+		})
 	}
 
 	if n, ok := e.(*ast.StarExpr); ok {
-		return map[string]interface{}{
+		return d.located(e, map[string]interface{}{
 			"kind":   "expression",
 			"type":   "star",
-			"target": DumpExpr(n.X, fset),
-		}
+			"target": d.dumpExpr(n.X),
+		})
 	}
 
 	if n, ok := e.(*ast.CallExpr); ok {
 
-		return DumpCall(n, fset)
+		return d.dumpCall(n)
 	}
 
 	if n, ok := e.(*ast.ParenExpr); ok {
-		return map[string]interface{}{
-			"kind":     "expression",
-			"type":     "paren",
-			"target":   DumpExpr(n.X, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":   "expression",
+			"type":   "paren",
+			"target": d.dumpExpr(n.X),
+		})
 	}
 
 	if n, ok := e.(*ast.SelectorExpr); ok {
-		lhs := DumpExpr(n.X, fset)
+		if isPackage, known := d.isPackage(n.X); known {
+			if isPackage {
+				return d.located(e, map[string]interface{}{
+					"kind":      "expression",
+					"type":      "identifier",
+					"qualifier": d.dumpIdent(n.X.(*ast.Ident)),
+					"value":     d.dumpIdent(n.Sel),
+				})
+			}
+
+			return d.located(e, map[string]interface{}{
+				"kind":   "expression",
+				"type":   "selector",
+				"target": d.dumpExpr(n.X),
+				"field":  d.dumpIdent(n.Sel),
+			})
+		}
+
 		// If the left hand side is just an identifier without a further qualifier,
 		// assume that this is a qualified expression rather than a method call.
 		// this is not correct in all cases, but ensuring correctness is outside
-		// of the scope of a lowly parser such as goblin.
-		if lhs["type"] == "identifier" && lhs["qualifier"] == nil {
-			return map[string]interface{}{
+		// of the scope of a lowly parser such as goblin (without Options.Types).
+		if x, ok := n.X.(*ast.Ident); ok {
+			return d.located(e, map[string]interface{}{
 				"kind":      "expression",
 				"type":      "identifier",
-				"qualifier": lhs["value"],
-				"value":     DumpIdent(n.Sel, fset),
-				"position":  DumpPosition(fset.Position(e.Pos())),
-			}
+				"qualifier": d.dumpIdent(x),
+				"value":     d.dumpIdent(n.Sel),
+			})
 		}
 
-		return map[string]interface{}{
-			"kind":     "expression",
-			"type":     "selector",
-			"target":   lhs,
-			"field":    DumpIdent(n.Sel, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":   "expression",
+			"type":   "selector",
+			"target": d.dumpExpr(n.X),
+			"field":  d.dumpIdent(n.Sel),
+		})
 	}
 
 	if n, ok := e.(*ast.TypeAssertExpr); ok {
-		return map[string]interface{}{
+		// the x.(type) of a type switch doesn't assert any type.
+		var asserted map[string]interface{}
+		if n.Type != nil {
+			asserted = d.dumpExprAsType(n.Type)
+		}
+
+		return d.located(e, map[string]interface{}{
 			"kind":     "expression",
 			"type":     "type-assert",
-			"target":   DumpExpr(n.X, fset),
-			"asserted": DumpExprAsType(n.Type, fset),
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+			"target":   d.dumpExpr(n.X),
+			"asserted": asserted,
+		})
 	}
 
 	if n, ok := e.(*ast.UnaryExpr); ok {
-		return map[string]interface{}{
-			"kind":     "unary",
-			"target":   DumpExpr(n.X, fset),
+		res := d.located(n, map[string]interface{}{
+			"kind":     "expression",
+			"type":     "unary",
+			"target":   d.dumpExpr(n.X),
 			"operator": n.Op.String(),
-			"position": DumpPosition(fset.Position(n.Pos())),
+		})
+
+		if d.format() == FormatV1 {
+			res["kind"] = "unary"
+			delete(res, "type")
 		}
+
+		return res
 	}
 
 	if n, ok := e.(*ast.SliceExpr); ok {
-		return map[string]interface{}{
-			"kind":     "expression",
-			"type":     "slice",
-			"target":   DumpExpr(n.X, fset),
-			"low":      DumpExpr(n.Low, fset),
-			"high":     DumpExpr(n.High, fset),
-			"max":      DumpExpr(n.Max, fset),
-			"three":    n.Slice3,
-			"position": DumpPosition(fset.Position(e.Pos())),
-		}
+		return d.located(e, map[string]interface{}{
+			"kind":   "expression",
+			"type":   "slice",
+			"target": d.dumpExpr(n.X),
+			"low":    d.dumpExpr(n.Low),
+			"high":   d.dumpExpr(n.High),
+			"max":    d.dumpExpr(n.Max),
+			"three":  n.Slice3,
+		})
 	}
 
 	if n, ok := e.(*ast.KeyValueExpr); ok {
-		return map[string]interface{}{
+		return d.located(e, map[string]interface{}{
 			"kind":  "expression",
 			"type":  "key-value",
-			"key":   DumpExpr(n.Key, fset),
-			"value": DumpExpr(n.Value, fset),
-		}
+			"key":   d.dumpExpr(n.Key),
+			"value": d.dumpExpr(n.Value),
+		})
 	}
 
 	if n, ok := e.(*ast.BadExpr); ok {
-		pos := fset.PositionFor(n.From, true)
-		Perish(pos, "internal_error", "encountered BadExpr")
+		pos := d.position(n.From)
+		return d.unsupported(n, pos, "internal_error", "encountered BadExpr")
 	}
 
 	typ := reflect.TypeOf(e).String()
-	Perish(fset.Position(e.Pos()), "unexpected_node", typ)
-	panic("unreachable")
+	return d.unsupported(e, d.position(e.Pos()), "unexpected_node", typ)
 }
 
-func DumpExprs(exprs []ast.Expr, fset *token.FileSet) []interface{} {
+func (d *Dumper) dumpExprs(exprs []ast.Expr) []interface{} {
 	values := make([]interface{}, len(exprs))
 	for i, v := range exprs {
-		values[i] = DumpExpr(v, fset)
+		values[i] = d.dumpExpr(v)
 	}
 
 	return values
 }
 
-func DumpBinaryExpr(b *ast.BinaryExpr, fset *token.FileSet) map[string]interface{} {
-	return map[string]interface{}{
-		"type":     "expression",
-		"kind":     "binary",
-		"left":     DumpExpr(b.X, fset),
-		"right":    DumpExpr(b.Y, fset),
+func (d *Dumper) dumpExprsAsType(exprs []ast.Expr) []interface{} {
+	values := make([]interface{}, len(exprs))
+	for i, v := range exprs {
+		values[i] = d.dumpExprAsType(v)
+	}
+
+	return values
+}
+
+func (d *Dumper) dumpBinaryExpr(b *ast.BinaryExpr) map[string]interface{} {
+	res := d.located(b, map[string]interface{}{
+		"kind":     "expression",
+		"type":     "binary",
+		"left":     d.dumpExpr(b.X),
+		"right":    d.dumpExpr(b.Y),
 		"operator": b.Op.String(),
-		"position": DumpPosition(fset.Position(b.Pos())),
+	})
+
+	if d.format() == FormatV1 {
+		res["kind"], res["type"] = "binary", "expression"
 	}
+
+	return res
 }
 
-func DumpBasicLit(l *ast.BasicLit, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpBasicLit(l *ast.BasicLit) map[string]interface{} {
 	if l == nil {
 		return nil
 	}
 
-	return map[string]interface{}{
-		"kind":     "literal",
-		"type":     l.Kind.String(),
-		"value":    l.Value,
-		"position": DumpPosition(fset.Position(l.Pos())),
-	}
+	return d.located(l, map[string]interface{}{
+		"kind":  "literal",
+		"type":  l.Kind.String(),
+		"value": l.Value,
+	})
 }
 
-func DumpField(f *ast.Field, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpField(f *ast.Field) map[string]interface{} {
 
 	nameCount := 0
 	if f.Names != nil {
@@ -464,33 +749,33 @@ func DumpField(f *ast.Field, fset *token.FileSet) map[string]interface{} {
 	names := make([]interface{}, nameCount)
 	if f.Names != nil {
 		for i, v := range f.Names {
-			names[i] = DumpIdent(v, fset)
+			names[i] = d.dumpIdent(v)
 		}
 	}
 
-	return map[string]interface{}{
+	return d.withComments(d.located(f, map[string]interface{}{
 		"kind":          "field",
 		"names":         names,
-		"declared-type": DumpExprAsType(f.Type, fset),
-		"tag":           DumpBasicLit(f.Tag, fset),
-	}
+		"declared-type": d.dumpExprAsType(f.Type),
+		"tag":           d.dumpBasicLit(f.Tag),
+	}), f.Doc, f.Comment)
 }
 
-func DumpFields(fs *ast.FieldList, fset *token.FileSet) []map[string]interface{} {
+func (d *Dumper) dumpFields(fs *ast.FieldList) []map[string]interface{} {
 	if fs == nil {
 		return nil
 	}
 
 	results := make([]map[string]interface{}, len(fs.List))
 	for i, v := range fs.List {
-		results[i] = DumpField(v, fset)
+		results[i] = d.dumpField(v)
 	}
 
 	return results
 }
 
-func DumpCommentGroup(g *ast.CommentGroup, fset *token.FileSet) []string {
-	if g == nil {
+func (d *Dumper) dumpCommentGroup(g *ast.CommentGroup) []string {
+	if g == nil || d.opts.Comments == OmitComments {
 		return []string{}
 	}
 
@@ -502,83 +787,194 @@ func DumpCommentGroup(g *ast.CommentGroup, fset *token.FileSet) []string {
 	return result
 }
 
-func DumpTypeAlias(t *ast.TypeSpec, fset *token.FileSet) map[string]interface{} {
-	return map[string]interface{}{
-		"kind":     "decl",
-		"type":     "type-alias",
-		"name":     DumpIdent(t.Name, fset),
-		"value":    DumpExprAsType(t.Type, fset),
-		"comments": DumpCommentGroup(t.Comment, fset),
-		"position": DumpPosition(fset.Position(t.Pos())),
+// withComments gives a declaration, spec or field its leading "doc" comment
+// and its trailing "comment". Format version 1 only had "comments" on some
+// of them, holding one or the other, so it's left alone there.
+func (d *Dumper) withComments(m map[string]interface{}, doc, comment *ast.CommentGroup) map[string]interface{} {
+	if d.format() == FormatV1 {
+		return m
 	}
+
+	delete(m, "comments")
+	m["doc"] = d.dumpCommentGroup(doc)
+	m["comment"] = d.dumpCommentGroup(comment)
+	return m
+}
+
+// withDirectives gives a function or method the directives in its doc
+// comment, such as //go:noinline or //export.
+func (d *Dumper) withDirectives(m map[string]interface{}, doc *ast.CommentGroup) map[string]interface{} {
+	m["directives"] = d.dumpDirectives([]*ast.CommentGroup{doc})
+	return m
 }
 
-func DumpCall(c *ast.CallExpr, fset *token.FileSet) map[string]interface{} {
-	if callee, ok := c.Fun.(*ast.Ident); ok {
+func (d *Dumper) dumpTypeAlias(decl *ast.GenDecl) map[string]interface{} {
+	t := decl.Specs[0].(*ast.TypeSpec)
+
+	// The parser gives an ungrouped spec's doc comment to its GenDecl,
+	// which this shape leaves out.
+	doc := t.Doc
+	if doc == nil {
+		doc = decl.Doc
+	}
+
+	return d.withComments(d.located(t, map[string]interface{}{
+		"kind":        "decl",
+		"type":        "type-alias",
+		"alias":       t.Assign != token.NoPos,
+		"name":        d.dumpIdent(t.Name),
+		"type-params": d.dumpFields(t.TypeParams),
+		"value":       d.dumpExprAsType(t.Type),
+		"comments":    d.dumpCommentGroup(t.Comment),
+	}), doc, t.Comment)
+}
+
+func (d *Dumper) dumpTypeSpec(t *ast.TypeSpec) map[string]interface{} {
+	return d.withComments(d.located(t, map[string]interface{}{
+		"kind":        "spec",
+		"type":        "type",
+		"alias":       t.Assign != token.NoPos,
+		"name":        d.dumpIdent(t.Name),
+		"type-params": d.dumpFields(t.TypeParams),
+		"value":       d.dumpExprAsType(t.Type),
+		"comments":    d.dumpCommentGroup(t.Comment),
+	}), t.Doc, t.Comment)
+}
+
+func (d *Dumper) dumpCall(c *ast.CallExpr) map[string]interface{} {
+	if callee, ok := c.Fun.(*ast.Ident); ok && d.builtin(callee) {
 		if callee.Name == "new" {
-			return map[string]interface{}{
+			if len(c.Args) != 1 {
+				return d.wrongArguments(c, "new")
+			}
+
+			return d.located(c, map[string]interface{}{
 				"kind":     "expression",
 				"type":     "new",
-				"argument": DumpExprAsType(c.Args[0], fset),
-				"position": DumpPosition(fset.Position(c.Pos())),
-			}
+				"argument": d.dumpExprAsType(c.Args[0]),
+			})
 		}
 
 		if callee.Name == "make" {
-			return map[string]interface{}{
+			if len(c.Args) == 0 {
+				return d.wrongArguments(c, "make")
+			}
+
+			return d.located(c, map[string]interface{}{
 				"kind":     "expression",
 				"type":     "make",
-				"argument": DumpExprAsType(c.Args[0], fset),
-				"rest":     DumpExprs(c.Args[1:], fset),
-				"position": DumpPosition(fset.Position(c.Pos())),
+				"argument": d.dumpExprAsType(c.Args[0]),
+				"rest":     d.dumpExprs(c.Args[1:]),
+			})
+		}
+	}
+
+	// with type information, we know for sure whether the LHS is a type.
+	if isType, known := d.isType(c.Fun); known {
+		if isType {
+			if len(c.Args) != 1 {
+				return d.wrongArguments(c, "conversion")
 			}
+
+			return d.located(c, map[string]interface{}{
+				"kind":       "expression",
+				"type":       "cast",
+				"target":     d.dumpExpr(c.Args[0]),
+				"coerced-to": d.dumpExprAsType(c.Fun),
+			})
 		}
+
+		return d.dumpPlainCall(c)
 	}
 
-	// try to parse the LHS as a type. if it succeeds and is *not* an identifier name,
+	// otherwise, try to parse the LHS as a type. if it succeeds and is *not* an identifier name,
 	// it's a cast. currently, we don't have any heuristics for determining whether an
 	// identifier is a typename (we don't even do the obvious cases like int8, float64
 	// et cetera). such heuristics can't be perfectly accurate due to cross-module type
 	// declarations, so it's probably more morally-correct, if less helpful, to treat them
-	// as function calls and disambiguate them at a further stage.
-	callee := AttemptExprAsType(c.Fun, fset)
+	// as function calls and disambiguate them at a further stage (or use Options.Types).
+	// the same goes for instantiations: `f[int](x)` is far more likely to be a call to a
+	// generic function than a conversion to a generic type, and `fns[i](x)` looks just
+	// like it.
+	callee := d.attemptExprAsType(c.Fun)
+
+	if callee != nil && callee["type"] != "identifier" && callee["type"] != "instantiation" {
+		if len(c.Args) != 1 {
+			return d.wrongArguments(c, "conversion")
+		}
 
-	if callee != nil && callee["type"] != "identifier" {
-		return map[string]interface{}{
+		return d.located(c, map[string]interface{}{
 			"kind":       "expression",
 			"type":       "cast",
-			"target":     DumpExpr(c.Args[0], fset),
+			"target":     d.dumpExpr(c.Args[0]),
 			"coerced-to": callee,
-			"position":   DumpPosition(fset.Position(c.Pos())),
-		}
+		})
 	}
 
-	callee = DumpExpr(c.Fun, fset)
+	return d.dumpPlainCall(c)
+}
 
-	return map[string]interface{}{
+// wrongArguments reports c, a call to new or make or a conversion, as having
+// the wrong number of arguments.
+func (d *Dumper) wrongArguments(c *ast.CallExpr, what string) map[string]interface{} {
+	reason := what + " with " + strconv.Itoa(len(c.Args)) + " arguments"
+	return d.unsupported(c, d.position(c.Pos()), "wrong_argument_count", reason)
+}
+
+func (d *Dumper) dumpPlainCall(c *ast.CallExpr) map[string]interface{} {
+	res := d.located(c, map[string]interface{}{
 		"kind":      "expression",
 		"type":      "call",
-		"function":  callee,
-		"arguments": DumpExprs(c.Args, fset),
+		"function":  d.dumpExpr(c.Fun),
+		"arguments": d.dumpExprs(c.Args),
 		"ellipsis":  c.Ellipsis != token.NoPos,
-		"position":  DumpPosition(fset.Position(c.Pos())),
+	})
+
+	if callee, ok := c.Fun.(*ast.Ident); ok && d.builtin(callee) {
+		res["builtin"] = true
 	}
+
+	return res
 }
 
-func DumpImport(spec *ast.ImportSpec, fset *token.FileSet) map[string]interface{} {
-	res := map[string]interface{}{
+// builtin reports whether i refers to one of Go's built-in functions rather
+// than to something shadowing it. With type information we know for sure.
+// Otherwise we rely on the parser having resolved i against the declarations
+// in its file: it's a builtin if it wasn't resolved, and isn't declared
+// anywhere else in the package being dumped either.
+func (d *Dumper) builtin(i *ast.Ident) bool {
+	if d.opts.Types != nil {
+		if obj, ok := d.opts.Types.Info.Uses[i]; ok {
+			_, ok := obj.(*types.Builtin)
+			return ok
+		}
+	}
+
+	if i.Obj != nil || d.packageScope[i.Name] {
+		return false
+	}
+
+	_, ok := types.Universe.Lookup(i.Name).(*types.Builtin)
+	return ok
+}
+
+func (d *Dumper) dumpImport(spec *ast.ImportSpec) map[string]interface{} {
+	res := d.located(spec, map[string]interface{}{
 		"type":     "import",
-		"doc":      DumpCommentGroup(spec.Doc, fset),
-		"comments": DumpCommentGroup(spec.Comment, fset),
-		"name":     DumpIdent(spec.Name, fset),
+		"doc":      d.dumpCommentGroup(spec.Doc),
+		"comments": d.dumpCommentGroup(spec.Comment),
+		"name":     d.dumpIdent(spec.Name),
 		"path":     strings.Trim(spec.Path.Value, "\""),
-		"position": DumpPosition(fset.Position(spec.Pos())),
+	})
+
+	if d.format() != FormatV1 {
+		res["kind"] = "spec"
 	}
 
-	return res
+	return d.withComments(res, spec.Doc, spec.Comment)
 }
 
-func DumpValue(kind string, spec *ast.ValueSpec, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpValue(kind string, spec *ast.ValueSpec) map[string]interface{} {
 	givenValues := []ast.Expr{}
 	if spec.Values != nil {
 		givenValues = spec.Values
@@ -586,156 +982,150 @@ func DumpValue(kind string, spec *ast.ValueSpec, fset *token.FileSet) map[string
 
 	processedValues := make([]interface{}, len(givenValues))
 	for i, v := range givenValues {
-		processedValues[i] = DumpExpr(v, fset)
+		processedValues[i] = d.dumpExpr(v)
 	}
 
 	processedNames := make([]interface{}, len(spec.Names))
 	for i, v := range spec.Names {
-		processedNames[i] = DumpIdent(v, fset)
+		processedNames[i] = d.dumpIdent(v)
 	}
 
-	return map[string]interface{}{
+	return d.withComments(d.located(spec, map[string]interface{}{
 		"kind":          "spec",
 		"type":          kind,
 		"names":         processedNames,
-		"declared-type": AttemptExprAsType(spec.Type, fset),
+		"declared-type": d.attemptExprAsType(spec.Type),
 		"values":        processedValues,
-		"comments":      DumpCommentGroup(spec.Comment, fset),
-		"position":      DumpPosition(fset.Position(spec.Pos())),
-	}
+		"comments":      d.dumpCommentGroup(spec.Comment),
+	}), spec.Doc, spec.Comment)
 }
 
-func DumpGenDecl(decl *ast.GenDecl, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpGenDecl(decl *ast.GenDecl) map[string]interface{} {
 	prettyToken := ""
 	results := make([]interface{}, len(decl.Specs))
 	switch decl.Tok {
 	case token.TYPE:
-		if len(decl.Specs) != 1 {
-			pos := fset.PositionFor(decl.Pos(), true)
-			Perish(pos, "syntax_error", "unexpected number of tokens ("+string(len(decl.Specs))+") in type alias (expected 1)")
+		if d.opts.Shape == LegacyTypeAliases && len(decl.Specs) == 1 {
+			// EARLY RETURN
+			return d.dumpTypeAlias(decl)
+		}
+
+		prettyToken = "type"
+		for i, v := range decl.Specs {
+			results[i] = d.dumpTypeSpec(v.(*ast.TypeSpec))
 		}
-		// EARLY RETURN
-		return DumpTypeAlias(decl.Specs[0].(*ast.TypeSpec), fset)
 
 	case token.IMPORT:
 		prettyToken = "import"
 		for i, v := range decl.Specs {
-			results[i] = DumpImport(v.(*ast.ImportSpec), fset)
+			results[i] = d.dumpImport(v.(*ast.ImportSpec))
 		}
 	case token.CONST:
 		prettyToken = "const"
 		for i, v := range decl.Specs {
-			results[i] = DumpValue("const", v.(*ast.ValueSpec), fset)
+			results[i] = d.dumpValue("const", v.(*ast.ValueSpec))
 		}
 
 	case token.VAR:
 		prettyToken = "var"
 		for i, v := range decl.Specs {
-			results[i] = DumpValue("var", v.(*ast.ValueSpec), fset)
+			results[i] = d.dumpValue("var", v.(*ast.ValueSpec))
 		}
 	default:
-		pos := fset.PositionFor(decl.Pos(), true)
-		Perish(pos, "unrecognized_token", decl.Tok.String())
+		pos := d.position(decl.Pos())
+		return d.unsupported(decl, pos, "unrecognized_token", decl.Tok.String())
 	}
 
-	return map[string]interface{}{
-		"kind":     "decl",
-		"type":     prettyToken,
-		"specs":    results,
-		"position": DumpPosition(fset.Position(decl.Pos())),
-	}
+	return d.withComments(d.located(decl, map[string]interface{}{
+		"kind":  "decl",
+		"type":  prettyToken,
+		"specs": results,
+	}), decl.Doc, nil)
 }
 
-func DumpStmt(s ast.Stmt, fset *token.FileSet) interface{} {
+func (d *Dumper) dumpStmt(s ast.Stmt) interface{} {
 	if s == nil {
 		return nil
 	}
 
 	if n, ok := s.(*ast.ReturnStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "return",
-			"values":   DumpExprs(n.Results, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind":   "statement",
+			"type":   "return",
+			"values": d.dumpExprs(n.Results),
+		})
 	}
 
 	if n, ok := s.(*ast.AssignStmt); ok {
 		if n.Tok == token.ASSIGN {
-			return map[string]interface{}{
-				"kind":     "statement",
-				"type":     "assign",
-				"left":     DumpExprs(n.Lhs, fset),
-				"right":    DumpExprs(n.Rhs, fset),
-				"position": DumpPosition(fset.Position(n.Pos())),
-			}
+			return d.located(n, map[string]interface{}{
+				"kind":  "statement",
+				"type":  "assign",
+				"left":  d.dumpExprs(n.Lhs),
+				"right": d.dumpExprs(n.Rhs),
+			})
 
 		} else if n.Tok == token.DEFINE {
-			return map[string]interface{}{
-				"kind":     "statement",
-				"type":     "define",
-				"left":     DumpExprs(n.Lhs, fset),
-				"right":    DumpExprs(n.Rhs, fset),
-				"position": DumpPosition(fset.Position(n.Pos())),
-			}
+			return d.located(n, map[string]interface{}{
+				"kind":  "statement",
+				"type":  "define",
+				"left":  d.dumpExprs(n.Lhs),
+				"right": d.dumpExprs(n.Rhs),
+			})
 		} else {
 			tok := n.Tok.String()
-			return map[string]interface{}{
+			return d.located(n, map[string]interface{}{
 				"kind":     "statement",
 				"type":     "assign-operator",
 				"operator": tok[0 : len(tok)-1],
-				"left":     DumpExprs(n.Lhs, fset),
-				"right":    DumpExprs(n.Rhs, fset),
-				"position": DumpPosition(fset.Position(n.Pos())),
-			}
+				"left":     d.dumpExprs(n.Lhs),
+				"right":    d.dumpExprs(n.Rhs),
+			})
 		}
 
 	}
 
 	if n, ok := s.(*ast.EmptyStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "empty",
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind": "statement",
+			"type": "empty",
+		})
 	}
 
 	if n, ok := s.(*ast.ExprStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":  "statement",
 			"type":  "expression",
-			"value": DumpExpr(n.X, fset),
-		}
+			"value": d.dumpExpr(n.X),
+		})
 	}
 
 	if n, ok := s.(*ast.LabeledStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "labeled",
-			"label":     DumpIdent(n.Label, fset),
-			"statement": DumpStmt(n.Stmt, fset),
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+			"label":     d.dumpIdent(n.Label),
+			"statement": d.dumpStmt(n.Stmt),
+		})
 	}
 
 	if n, ok := s.(*ast.BranchStmt); ok {
-		result := map[string]interface{}{
-			"kind":     "statement",
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		result := d.located(n, map[string]interface{}{
+			"kind": "statement",
+		})
 
 		switch n.Tok {
 		case token.BREAK:
 			result["type"] = "break"
-			result["label"] = DumpIdent(n.Label, fset)
+			result["label"] = d.dumpIdent(n.Label)
 
 		case token.CONTINUE:
 			result["type"] = "continue"
-			result["label"] = DumpIdent(n.Label, fset)
+			result["label"] = d.dumpIdent(n.Label)
 
 		case token.GOTO:
 			result["type"] = "goto"
-			result["label"] = DumpIdent(n.Label, fset)
+			result["label"] = d.dumpIdent(n.Label)
 
 		case token.FALLTHROUGH:
 			result["type"] = "fallthrough"
@@ -745,235 +1135,336 @@ func DumpStmt(s ast.Stmt, fset *token.FileSet) interface{} {
 	}
 
 	if n, ok := s.(*ast.RangeStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "range",
-			"key":       DumpExpr(n.Key, fset),
-			"value":     DumpExpr(n.Value, fset),
-			"target":    DumpExpr(n.X, fset),
+			"key":       d.dumpExpr(n.Key),
+			"value":     d.dumpExpr(n.Value),
+			"target":    d.dumpExpr(n.X),
 			"is-assign": n.Tok == token.DEFINE,
-			"body":      DumpBlock(n.Body, fset),
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+			"body":      d.dumpBlock(n.Body),
+		})
 	}
 	if n, ok := s.(*ast.DeclStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "declaration",
-			"target":   DumpDecl(n.Decl, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind":   "statement",
+			"type":   "declaration",
+			"target": d.dumpDecl(n.Decl),
+		})
 	}
 
 	if n, ok := s.(*ast.DeferStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "defer",
-			"target":   DumpCall(n.Call, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind":   "statement",
+			"type":   "defer",
+			"target": d.dumpCall(n.Call),
+		})
 	}
 
 	if n, ok := s.(*ast.IfStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "if",
-			"init":      DumpStmt(n.Init, fset),
-			"condition": DumpExpr(n.Cond, fset),
-			"body":      DumpBlock(n.Body, fset),
-			"else":      DumpStmt(n.Else, fset),
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+			"init":      d.dumpStmt(n.Init),
+			"condition": d.dumpExpr(n.Cond),
+			"body":      d.dumpBlock(n.Body),
+			"else":      d.dumpStmt(n.Else),
+		})
 	}
 
 	if n, ok := s.(*ast.BlockStmt); ok {
-		return DumpBlockAsStmt(n, fset)
+		return d.dumpBlockAsStmt(n)
 	}
 
 	if n, ok := s.(*ast.ForStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "for",
-			"init":      DumpStmt(n.Init, fset),
-			"condition": DumpExpr(n.Cond, fset),
-			"post":      DumpStmt(n.Post, fset),
-			"body":      DumpBlock(n.Body, fset),
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+			"init":      d.dumpStmt(n.Init),
+			"condition": d.dumpExpr(n.Cond),
+			"post":      d.dumpStmt(n.Post),
+			"body":      d.dumpBlock(n.Body),
+		})
 	}
 
 	if n, ok := s.(*ast.GoStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "go",
-			"target":   DumpCall(n.Call, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind":   "statement",
+			"type":   "go",
+			"target": d.dumpCall(n.Call),
+		})
 	}
 
 	if n, ok := s.(*ast.SendStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "send",
-			"channel":  DumpExpr(n.Chan, fset),
-			"value":    DumpExpr(n.Value, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind":    "statement",
+			"type":    "send",
+			"channel": d.dumpExpr(n.Chan),
+			"value":   d.dumpExpr(n.Value),
+		})
 	}
 
 	if n, ok := s.(*ast.SelectStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "select",
-			"body":     DumpBlock(n.Body, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.located(n, map[string]interface{}{
+			"kind": "statement",
+			"type": "select",
+			"body": d.dumpBlock(n.Body),
+		})
 	}
 
 	if n, ok := s.(*ast.IncDecStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "crement",
-			"target":    DumpExpr(n.X, fset),
+			"target":    d.dumpExpr(n.X),
 			"operation": n.Tok.String(),
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+		})
 	}
 
 	if n, ok := s.(*ast.SwitchStmt); ok {
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "switch",
-			"init":      DumpStmt(n.Init, fset),
-			"condition": DumpExpr(n.Tag, fset),
-			"body":      DumpBlock(n.Body, fset),
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+			"init":      d.dumpStmt(n.Init),
+			"condition": d.dumpExpr(n.Tag),
+			"body":      d.dumpBlock(n.Body),
+		})
 	}
 
 	if n, ok := s.(*ast.TypeSwitchStmt); ok {
-		return map[string]interface{}{
-			"kind":     "statement",
-			"type":     "type-switch",
-			"init":     DumpStmt(n.Init, fset),
-			"assign":   DumpStmt(n.Assign, fset),
-			"body":     DumpBlock(n.Body, fset),
-			"position": DumpPosition(fset.Position(n.Pos())),
+		body := make([]interface{}, len(n.Body.List))
+		for i, v := range n.Body.List {
+			if c, ok := v.(*ast.CaseClause); ok {
+				body[i] = d.dumpCaseClause(c, d.dumpTypeCase)
+			} else {
+				body[i] = d.dumpStmt(v)
+			}
 		}
+
+		return d.located(n, map[string]interface{}{
+			"kind":   "statement",
+			"type":   "type-switch",
+			"init":   d.dumpStmt(n.Init),
+			"assign": d.dumpStmt(n.Assign),
+			"body":   body,
+		})
 	}
 
 	if n, ok := s.(*ast.CommClause); ok {
 		stmts := make([]interface{}, len(n.Body))
 		for i, v := range n.Body {
-			stmts[i] = DumpStmt(v, fset)
+			stmts[i] = d.dumpStmt(v)
 		}
 
-		return map[string]interface{}{
+		return d.located(n, map[string]interface{}{
 			"kind":      "statement",
 			"type":      "select-clause",
-			"statement": DumpStmt(n.Comm, fset),
+			"statement": d.dumpStmt(n.Comm),
 			"body":      stmts,
-			"position":  DumpPosition(fset.Position(n.Pos())),
-		}
+		})
 
 	}
 
 	if n, ok := s.(*ast.CaseClause); ok {
-		exprs := make([]interface{}, len(n.Body))
-		for i, v := range n.Body {
-			exprs[i] = DumpStmt(v, fset)
-		}
-
-		return map[string]interface{}{
-			"kind":        "statement",
-			"type":        "case-clause",
-			"expressions": DumpExprs(n.List, fset),
-			"body":        exprs,
-			"position":    DumpPosition(fset.Position(n.Pos())),
-		}
+		return d.dumpCaseClause(n, d.dumpExpr)
 	}
 
 	if n, ok := s.(*ast.BadStmt); ok {
-		pos := fset.PositionFor(n.From, true)
-		Perish(pos, "internal_error", "encountered BadStmt")
+		pos := d.position(n.From)
+		return d.unsupported(n, pos, "internal_error", "encountered BadStmt")
 	}
 
 	typ := reflect.TypeOf(s).String()
-	pos := fset.PositionFor(s.Pos(), true)
-	Perish(pos, "unexpected_node", typ)
-	panic("unreachable")
+	pos := d.position(s.Pos())
+	return d.unsupported(s, pos, "unexpected_node", typ)
 }
 
-func DumpBlock(b *ast.BlockStmt, fset *token.FileSet) []interface{} {
+// dumpCaseClause dumps a case clause, using dumpCase for each of its cases.
+func (d *Dumper) dumpCaseClause(n *ast.CaseClause, dumpCase func(ast.Expr) map[string]interface{}) map[string]interface{} {
+	cases := make([]interface{}, len(n.List))
+	for i, v := range n.List {
+		cases[i] = dumpCase(v)
+	}
+
+	exprs := make([]interface{}, len(n.Body))
+	for i, v := range n.Body {
+		exprs[i] = d.dumpStmt(v)
+	}
+
+	return d.located(n, map[string]interface{}{
+		"kind":        "statement",
+		"type":        "case-clause",
+		"expressions": cases,
+		"body":        exprs,
+	})
+}
+
+// dumpTypeCase dumps a case of a type switch. Names, qualified names and
+// pointers to them are dumped as expressions, as they always have been, but
+// type literals such as `[]int` can only be dumped as types.
+func (d *Dumper) dumpTypeCase(e ast.Expr) map[string]interface{} {
+	inner := e
+	for {
+		switch n := inner.(type) {
+		case *ast.StarExpr:
+			inner = n.X
+		case *ast.ParenExpr:
+			inner = n.X
+		case *ast.ArrayType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.StructType:
+			return d.dumpExprAsType(e)
+		default:
+			return d.dumpExpr(e)
+		}
+	}
+}
+
+func (d *Dumper) dumpBlock(b *ast.BlockStmt) []interface{} {
 	if b == nil {
 		return nil
 	}
 	results := make([]interface{}, len(b.List))
 	for i, v := range b.List {
-		results[i] = DumpStmt(v, fset)
+		results[i] = d.dumpStmt(v)
 	}
 
 	return results
 }
 
-func DumpBlockAsStmt(b *ast.BlockStmt, fset *token.FileSet) map[string]interface{} {
-	return map[string]interface{}{
-		"kind":     "statement",
-		"type":     "block",
-		"body":     DumpBlock(b, fset),
-		"position": DumpPosition(fset.Position(b.Pos())),
+func (d *Dumper) dumpBlockAsStmt(b *ast.BlockStmt) map[string]interface{} {
+	return d.located(b, map[string]interface{}{
+		"kind": "statement",
+		"type": "block",
+		"body": d.dumpBlock(b),
+	})
+}
+
+func (d *Dumper) dumpFuncDecl(f *ast.FuncDecl) map[string]interface{} {
+	return d.withDirectives(d.withComments(d.located(f, map[string]interface{}{
+		"kind":        "decl",
+		"type":        "function",
+		"name":        d.dumpIdent(f.Name),
+		"body":        d.dumpBlock(f.Body),
+		"type-params": d.dumpFields(f.Type.TypeParams),
+		"params":      d.dumpFields(f.Type.Params),
+		"results":     d.dumpFields(f.Type.Results),
+		"comments":    d.dumpCommentGroup(f.Doc),
+	}), f.Doc, nil), f.Doc)
+}
+
+// Methods can't declare type parameters of their own, but a method on a
+// generic type names the type's parameters in its receiver, as in
+// `func (l *List[T]) Len() int`. SplitReceiverType peels those names off the
+// receiver type, returning the plain base type and the names. The receiver
+// itself is dumped as written, but the names are also reported as the
+// method's type parameters (without constraints, which only appear on the
+// type declaration).
+func SplitReceiverType(e ast.Expr) (ast.Expr, []*ast.Ident) {
+	switch n := e.(type) {
+	case *ast.ParenExpr:
+		return SplitReceiverType(n.X)
+
+	case *ast.StarExpr:
+		base, params := SplitReceiverType(n.X)
+		return &ast.StarExpr{Star: n.Star, X: base}, params
+
+	case *ast.IndexExpr:
+		if id, ok := n.Index.(*ast.Ident); ok {
+			return n.X, []*ast.Ident{id}
+		}
+
+	case *ast.IndexListExpr:
+		params := make([]*ast.Ident, len(n.Indices))
+		for i, v := range n.Indices {
+			id, ok := v.(*ast.Ident)
+			if !ok {
+				return e, nil
+			}
+			params[i] = id
+		}
+		return n.X, params
 	}
+
+	return e, nil
 }
 
-func DumpFuncDecl(f *ast.FuncDecl, fset *token.FileSet) map[string]interface{} {
-	return map[string]interface{}{
-		"kind":     "decl",
-		"type":     "function",
-		"name":     DumpIdent(f.Name, fset),
-		"body":     DumpBlock(f.Body, fset),
-		"params":   DumpFields(f.Type.Params, fset),
-		"results":  DumpFields(f.Type.Results, fset),
-		"comments": DumpCommentGroup(f.Doc, fset),
-		"position": DumpPosition(fset.Position(f.Pos())),
+func (d *Dumper) dumpReceiverTypeParams(params []*ast.Ident) []map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+
+	results := make([]map[string]interface{}, len(params))
+	for i, v := range params {
+		// Each name stands for a field of its own, which we locate as
+		// one so that only the name is annotated with its type.
+		field := &ast.Field{Names: []*ast.Ident{v}}
+		results[i] = d.withComments(d.located(field, map[string]interface{}{
+			"kind":          "field",
+			"names":         []interface{}{d.dumpIdent(v)},
+			"declared-type": nil,
+			"tag":           nil,
+		}), nil, nil)
 	}
+
+	return results
 }
 
-func DumpMethodDecl(f *ast.FuncDecl, fset *token.FileSet) map[string]interface{} {
-	return map[string]interface{}{
-		"kind":     "decl",
-		"type":     "method",
-		"receiver": DumpField(f.Recv.List[0], fset),
-		"name":     DumpIdent(f.Name, fset),
-		"body":     DumpBlock(f.Body, fset),
-		"params":   DumpFields(f.Type.Params, fset),
-		"results":  DumpFields(f.Type.Results, fset),
-		"comments": DumpCommentGroup(f.Doc, fset),
-		"position": DumpPosition(fset.Position(f.Pos())),
+// dumpReceiver dumps a method's receiver. The parser leaves the list empty for
+// `func () M()`, or when a file stops short after `func (`, in which case the
+// receiver's type is unsupported.
+func (d *Dumper) dumpReceiver(recv *ast.FieldList) map[string]interface{} {
+	if len(recv.List) > 0 {
+		return d.dumpField(recv.List[0])
 	}
+
+	pos := d.position(recv.Pos())
+	return d.withComments(d.located(recv, map[string]interface{}{
+		"kind":          "field",
+		"names":         []interface{}{},
+		"declared-type": d.unsupported(recv, pos, "missing_receiver", "method without a receiver"),
+		"tag":           nil,
+	}), nil, nil)
+}
+
+func (d *Dumper) dumpMethodDecl(f *ast.FuncDecl) map[string]interface{} {
+	var params []*ast.Ident
+	if len(f.Recv.List) > 0 {
+		_, params = SplitReceiverType(f.Recv.List[0].Type)
+	}
+
+	return d.withDirectives(d.withComments(d.located(f, map[string]interface{}{
+		"kind":        "decl",
+		"type":        "method",
+		"receiver":    d.dumpReceiver(f.Recv),
+		"name":        d.dumpIdent(f.Name),
+		"body":        d.dumpBlock(f.Body),
+		"type-params": d.dumpReceiverTypeParams(params),
+		"params":      d.dumpFields(f.Type.Params),
+		"results":     d.dumpFields(f.Type.Results),
+		"comments":    d.dumpCommentGroup(f.Doc),
+	}), f.Doc, nil), f.Doc)
 }
 
-func DumpDecl(n ast.Decl, fset *token.FileSet) map[string]interface{} {
+func (d *Dumper) dumpDecl(n ast.Decl) map[string]interface{} {
 	if decl, ok := n.(*ast.GenDecl); ok {
-		return DumpGenDecl(decl, fset)
+		return d.dumpGenDecl(decl)
 	}
 
 	if decl, ok := n.(*ast.FuncDecl); ok {
 		if decl.Recv == nil {
-			return DumpFuncDecl(decl, fset)
+			return d.dumpFuncDecl(decl)
 		} else {
-			return DumpMethodDecl(decl, fset)
+			return d.dumpMethodDecl(decl)
 		}
 	}
 
 	if decl, ok := n.(*ast.BadDecl); ok {
-		pos := fset.PositionFor(decl.From, true)
-		Perish(pos, "internal_error", "encountered BadDecl")
+		pos := d.position(decl.From)
+		return d.unsupported(decl, pos, "internal_error", "encountered BadDecl")
 	}
 
 	typ := reflect.TypeOf(n).String()
-	pos := fset.PositionFor(n.Pos(), true)
-	Perish(pos, "unexpected_node", typ)
-	panic("unreachable")
+	pos := d.position(n.Pos())
+	return d.unsupported(n, pos, "unexpected_node", typ)
 }
 
 func IsImport(d ast.Decl) bool {
@@ -984,7 +1475,12 @@ func IsImport(d ast.Decl) bool {
 	return false
 }
 
-func DumpFile(f *ast.File, fset *token.FileSet) ([]byte, error) {
+func (d *Dumper) dumpFile(f *ast.File) map[string]interface{} {
+	if d.format() != FormatV1 {
+		d.nodes = map[ast.Node]map[string]interface{}{}
+		defer func() { d.nodes = nil }()
+	}
+
 	decls := []interface{}{}
 	imps := []interface{}{}
 	if f.Decls != nil {
@@ -999,68 +1495,144 @@ func DumpFile(f *ast.File, fset *token.FileSet) ([]byte, error) {
 
 		decls = make([]interface{}, len(f.Decls))
 		for i, v := range f.Decls {
-			decls[i] = DumpDecl(v, fset)
+			decls[i] = d.dumpDecl(v)
 		}
 
+		// The imports are the first declarations, so they've been dumped
+		// already.
 		imps = make([]interface{}, len(imports))
-		for i, v := range imports {
-			imps[i] = DumpDecl(v, fset)
+		copy(imps, decls)
+	}
+
+	res := d.located(f, map[string]interface{}{
+		"kind":           "file",
+		"format-version": float64(d.format()),
+		"name":           d.dumpIdent(f.Name),
+		"comments":       d.dumpCommentGroup(f.Doc),
+		"declarations":   decls,
+		"imports":        imps,
+	})
+
+	res["directives"] = d.dumpDirectives(f.Comments)
+	if constraint := d.dumpBuildConstraint(f); constraint != nil {
+		res["build-constraint"] = constraint
+	}
+
+	if d.format() != FormatV1 {
+		res["all-comments"] = d.dumpAllComments(f, res)
+		return res
+	}
+
+	allComments := [][]string{}
+	if d.opts.Comments != OmitComments {
+		allComments = make([][]string, len(f.Comments))
+		for i, v := range f.Comments {
+			allComments[i] = d.dumpCommentGroup(v)
 		}
 	}
+	res["all-comments"] = allComments
+
+	return res
+}
+
+func (d *Dumper) DumpFile(f *ast.File) (res map[string]interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+	return d.dumpFile(f), d.collected()
+}
 
-	allComments := make([][]string, len(f.Comments))
-	for i, v := range f.Comments {
-		allComments[i] = DumpCommentGroup(v, fset)
+func (d *Dumper) DumpDecl(n ast.Decl) (res map[string]interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+	return d.dumpDecl(n), d.collected()
+}
+
+func (d *Dumper) DumpStmt(s ast.Stmt) (res interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+	return d.dumpStmt(s), d.collected()
+}
+
+func (d *Dumper) DumpExpr(e ast.Expr) (res map[string]interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+	return d.dumpExpr(e), d.collected()
+}
+
+// DumpFile is kept for callers that only want the JSON encoding of a file.
+func DumpFile(f *ast.File, fset *token.FileSet) ([]byte, error) {
+	res, err := NewDumper(fset, Options{}).DumpFile(f)
+	if err != nil {
+		return nil, err
 	}
 
-	return json.Marshal(map[string]interface{}{
-		"kind":         "file",
-		"name":         DumpIdent(f.Name, fset),
-		"comments":     DumpCommentGroup(f.Doc, fset),
-		"all-comments": allComments,
-		"declarations": decls,
-		"imports":      imps,
-	})
+	return json.Marshal(res)
+}
+
+// ParseStmt parses a statement. Due to a quirk in the go/parser API, the
+// statement has to be surrounded by a dummy function in a dummy file.
+//
+// Deprecated: ParseStmts returns just the statements, and a way to report
+// positions relative to them.
+func ParseStmt(fset *token.FileSet, s string) (*ast.File, error) {
+	return parser.ParseFile(fset, "stdin", "package p; func blah(foo int, bar float64) string { "+s+"}", 0)
 }
 
 func TestExpr(s string) map[string]interface{} {
 	fset := token.NewFileSet() // positions are relative to fset
 
-	f, err := parser.ParseExpr(s)
+	f, err := parser.ParseExprFrom(fset, "stdin", s, 0)
 	if err != nil {
 		panic(err.Error())
 	}
 
 	// Inspect the AST and print all identifiers and literals.
-	return DumpExpr(f, fset)
+	res, err := NewDumper(fset, Options{}).DumpExpr(f)
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return res
 }
 
 func TestFile(p string) []byte {
 	fset := token.NewFileSet()
 
-	file, err := os.Open(p)
+	src, err := os.ReadFile(p)
 	if err != nil {
 		return nil
 	}
-	info, err := file.Stat()
+
+	f, err := parser.ParseFile(fset, p, src, 0)
+
 	if err != nil {
-		return nil
+		panic(err.Error())
 	}
 
-	size := info.Size()
-	file.Close()
+	// Inspect the AST and print all identifiers and literals.
+	res, err := DumpFile(f, fset)
 
-	fset.AddFile(p, -1, int(size))
+	if err != nil {
+		panic(err.Error())
+	}
 
-	f, err := parser.ParseFile(fset, p, nil, 0)
+	return res
+}
+
+// TestDecl dumps one or more top-level declarations, with positions relative
+// to s.
+func TestDecl(s string) []interface{} {
+	fset := token.NewFileSet()
 
+	decls, origin, err := ParseDecls(fset, s)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	// Inspect the AST and print all identifiers and literals.
-	res, err := DumpFile(f, fset)
-
+	d := NewDumper(fset, Options{})
+	d.SetOrigin(origin)
+	res, err := d.DumpDecls(decls)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -1068,20 +1640,26 @@ func TestFile(p string) []byte {
 	return res
 }
 
+// TestStmt dumps a single statement, with positions relative to s.
 func TestStmt(s string) []byte {
 	fset := token.NewFileSet() // positions are relative to fset
 
-	f, err := parser.ParseFile(fset, "stdin", "package p; func blah(foo int, bar float64) string { "+s+"}", 0)
+	stmts, origin, err := ParseStmts(fset, s)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	// Inspect the AST and print all identifiers and literals.
-	res, err := DumpFile(f, fset)
+	if len(stmts) != 1 {
+		panic("expected a single statement, found " + strconv.Itoa(len(stmts)))
+	}
 
+	d := NewDumper(fset, Options{})
+	d.SetOrigin(origin)
+	res, err := d.DumpStmt(stmts[0])
 	if err != nil {
 		panic(err.Error())
 	}
 
-	return res
+	text, _ := json.Marshal(res)
+	return text
 }
@@ -1,12 +1,19 @@
 package goblin
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
 	"math"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 	"testing/quick"
 )
@@ -20,7 +27,7 @@ func TestRoundTripFloat(t *testing.T) {
 		needed := fmt.Sprintf("%f", flt)
 		gotten := TestExpr(needed)
 		result, _ := strconv.ParseFloat(gotten["value"].(string), 64)
-		return flt == result
+		return flt == result && checkSchema(DefaultFormat, gotten) == nil
 	}
 
 	if err := quick.Check(f, nil); err != nil {
@@ -37,44 +44,89 @@ type Fixture struct {
 func TestPackageFixtures(t *testing.T) {
 	fixtures := []Fixture{
 		Fixture{"helloworld",
-			"fixtures/packages/helloworld/helloworld.go",
-			"fixtures/packages/helloworld/helloworld.json",
+			"testdata/packages/helloworld/helloworld.go",
+			"testdata/packages/helloworld/helloworld.json",
 		},
 		Fixture{"simple type alias",
-			"fixtures/packages/simpletypealias/simpletypealias.go",
-			"fixtures/packages/simpletypealias/simpletypealias.json",
+			"testdata/packages/simpletypealias/simpletypealias.go",
+			"testdata/packages/simpletypealias/simpletypealias.json",
 		},
 		Fixture{"untyped top-level variable",
-			"fixtures/packages/untypedvar/untyped.go",
-			"fixtures/packages/untypedvar/untyped.json",
+			"testdata/packages/untypedvar/untyped.go",
+			"testdata/packages/untypedvar/untyped.json",
 		},
 		Fixture{"qualified type in function argument",
-			"fixtures/packages/qualifiedtype/qualified.go",
-			"fixtures/packages/qualifiedtype/qualified.json",
+			"testdata/packages/qualifiedtype/qualified.go",
+			"testdata/packages/qualifiedtype/qualified.json",
 		},
 		Fixture{"infinite for-loop",
-			"fixtures/packages/emptyfor/empty.go",
-			"fixtures/packages/emptyfor/empty.json",
+			"testdata/packages/emptyfor/empty.go",
+			"testdata/packages/emptyfor/empty.json",
 		},
 		Fixture{
 			"select statement",
-			"fixtures/packages/select/select.go",
-			"fixtures/packages/select/select.json",
+			"testdata/packages/select/select.go",
+			"testdata/packages/select/select.json",
 		},
 		Fixture{
 			"method declaration",
-			"fixtures/packages/methoddecl/method.go",
-			"fixtures/packages/methoddecl/method.json",
+			"testdata/packages/methoddecl/method.go",
+			"testdata/packages/methoddecl/method.json",
 		},
 		Fixture{
 			"map with interface type",
-			"fixtures/packages/interface_type/interface.go",
-			"fixtures/packages/interface_type/interface.json",
+			"testdata/packages/interface_type/interface.go",
+			"testdata/packages/interface_type/interface.json",
 		},
 		Fixture{
 			"empty function",
-			"fixtures/packages/emptyfunc/empty.go",
-			"fixtures/packages/emptyfunc/empty.json",
+			"testdata/packages/emptyfunc/empty.go",
+			"testdata/packages/emptyfunc/empty.json",
+		},
+		Fixture{
+			"generic functions",
+			"testdata/packages/genericfunc/generic.go",
+			"testdata/packages/genericfunc/generic.json",
+		},
+		Fixture{
+			"generic types",
+			"testdata/packages/generictype/generic.go",
+			"testdata/packages/generictype/generic.json",
+		},
+		Fixture{
+			"methods on a generic receiver",
+			"testdata/packages/genericmethod/method.go",
+			"testdata/packages/genericmethod/method.json",
+		},
+		Fixture{
+			"generic instantiations in types and calls",
+			"testdata/packages/instantiation/instantiation.go",
+			"testdata/packages/instantiation/instantiation.json",
+		},
+		Fixture{
+			"type-set constraints",
+			"testdata/packages/typeset/typeset.go",
+			"testdata/packages/typeset/typeset.json",
+		},
+		Fixture{
+			"grouped type declarations",
+			"testdata/packages/groupedtypes/grouped.go",
+			"testdata/packages/groupedtypes/grouped.json",
+		},
+		Fixture{
+			"type aliases and defined types",
+			"testdata/packages/aliasdecl/alias.go",
+			"testdata/packages/aliasdecl/alias.json",
+		},
+		Fixture{
+			"positions of clauses, stars and key-value pairs",
+			"testdata/packages/positions/positions.go",
+			"testdata/packages/positions/positions.json",
+		},
+		Fixture{
+			"type switches and type assertions",
+			"testdata/packages/typeswitch/typeswitch.go",
+			"testdata/packages/typeswitch/typeswitch.json",
 		},
 	}
 
@@ -102,47 +154,60 @@ func TestPackageFixtures(t *testing.T) {
 	}
 }
 
+func TestExprPositions(t *testing.T) {
+	right := TestExpr("a + b")["right"].(map[string]interface{})
+	pos := right["position"].(map[string]interface{})
+	if pos["filename"] != "stdin" || pos["column"] != float64(5) {
+		t.Errorf("Positioned b in a + b at %v", pos)
+	}
+}
+
 func TestExpressionFixtures(t *testing.T) {
 	fixtures := []Fixture{
 		Fixture{
 			"cast to array",
-			"fixtures/expressions/slicecast/slice.go.txt",
-			"fixtures/expressions/slicecast/slice.json",
+			"testdata/expressions/slicecast/slice.go.txt",
+			"testdata/expressions/slicecast/slice.json",
 		},
 		Fixture{
 			"cast to pointer",
-			"fixtures/expressions/ptrcast/ptr.go.txt",
-			"fixtures/expressions/ptrcast/ptr.json",
+			"testdata/expressions/ptrcast/ptr.go.txt",
+			"testdata/expressions/ptrcast/ptr.json",
 		},
 		Fixture{
 			"map literal",
-			"fixtures/expressions/mapliteral/map.go.txt",
-			"fixtures/expressions/mapliteral/map.json",
+			"testdata/expressions/mapliteral/map.go.txt",
+			"testdata/expressions/mapliteral/map.json",
 		},
 		Fixture{
 			"single qualifier",
-			"fixtures/expressions/singlequalifier/single.go.txt",
-			"fixtures/expressions/singlequalifier/single.json",
+			"testdata/expressions/singlequalifier/single.go.txt",
+			"testdata/expressions/singlequalifier/single.json",
 		},
 		Fixture{
 			"double qualifier",
-			"fixtures/expressions/doublequalifier/double.go.txt",
-			"fixtures/expressions/doublequalifier/double.json",
+			"testdata/expressions/doublequalifier/double.go.txt",
+			"testdata/expressions/doublequalifier/double.json",
 		},
 		Fixture{
 			"cast to chan",
-			"fixtures/expressions/chancast/chan.go.txt",
-			"fixtures/expressions/chancast/chan.json",
+			"testdata/expressions/chancast/chan.go.txt",
+			"testdata/expressions/chancast/chan.json",
 		},
 		Fixture{
 			"cast with parenthesized type",
-			"fixtures/expressions/parenintype/paren.go.txt",
-			"fixtures/expressions/parenintype/paren.json",
+			"testdata/expressions/parenintype/paren.go.txt",
+			"testdata/expressions/parenintype/paren.json",
 		},
 		Fixture{
 			"adding two identifiers",
-			"fixtures/expressions/addition/addition.go.txt",
-			"fixtures/expressions/addition/addition.json",
+			"testdata/expressions/addition/addition.go.txt",
+			"testdata/expressions/addition/addition.json",
+		},
+		Fixture{
+			"calling an instantiated generic function",
+			"testdata/expressions/genericcall/call.go.txt",
+			"testdata/expressions/genericcall/call.json",
 		},
 	}
 
@@ -166,6 +231,382 @@ func TestExpressionFixtures(t *testing.T) {
 	}
 }
 
+// typeCheck, given as Options.Types to dumpSource, has it type-check the
+// source first, as a package of its own.
+var typeCheck = &Checked{}
+
+// dumpSource parses src as the file name (reading the file if src is nil, as
+// parser.ParseFile does), dumps it with opts, and checks that the dump matches
+// the schema and survives Unmarshal.
+func dumpSource(t *testing.T, name string, src interface{}, mode parser.Mode, opts Options) map[string]interface{} {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, mode)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	if opts.Types == typeCheck {
+		if opts.Types, err = Check(fset, "p", []*ast.File{f}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	res, err := NewDumper(fset, opts).DumpFile(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if err := checkSchema(opts.Format, res); err != nil {
+		t.Errorf("%s with %+v: %v", name, opts, err)
+	}
+	checkUnmarshal(t, name, res)
+
+	return res
+}
+
+func dumpFixture(t *testing.T, p string, opts Options) map[string]interface{} {
+	return dumpSource(t, p, nil, 0, opts)
+}
+
+func TestLegacyTypeAlias(t *testing.T) {
+	opts := Options{Shape: LegacyTypeAliases}
+
+	gotten := dumpFixture(t, "testdata/packages/simpletypealias/simpletypealias.go", opts)
+	decl := gotten["declarations"].([]interface{})[0].(map[string]interface{})
+	if decl["type"] != "type-alias" {
+		t.Error("Didn't dump a single type declaration as a type alias")
+	}
+
+	gotten = dumpFixture(t, "testdata/packages/groupedtypes/grouped.go", opts)
+	decl = gotten["declarations"].([]interface{})[0].(map[string]interface{})
+	if decl["type"] != "type" || len(decl["specs"].([]interface{})) != 3 {
+		t.Error("Didn't dump a grouped type declaration as a list of specs")
+	}
+}
+
+func TestFormatV1(t *testing.T) {
+	expr, _ := parser.ParseExpr("-a + true")
+	gotten, _ := NewDumper(token.NewFileSet(), Options{Format: FormatV1}).DumpExpr(expr)
+	if gotten["kind"] != "binary" || gotten["type"] != "expression" {
+		t.Error("Didn't dump a binary expression in the v1 shape")
+	}
+
+	left := gotten["left"].(map[string]interface{})
+	if _, ok := left["type"]; left["kind"] != "unary" || ok {
+		t.Error("Didn't dump a unary expression in the v1 shape")
+	}
+
+	right := gotten["right"].(map[string]interface{})
+	if right["kind"] != "literal" || right["type"] != "BOOL" {
+		t.Error("Wrapped a v1 boolean in an identifier expression")
+	}
+
+	file := dumpFixture(t, "testdata/packages/qualifiedtype/qualified.go", Options{Format: FormatV1})
+	spec := file["imports"].([]interface{})[0].(map[string]interface{})["specs"].([]interface{})[0].(map[string]interface{})
+	if _, ok := spec["kind"]; ok || file["format-version"] != float64(1) {
+		t.Error("Didn't dump a v1 file")
+	}
+
+	file = dumpFixture(t, "testdata/packages/helloworld/helloworld.go", Options{})
+	if file["format-version"] != float64(1) {
+		t.Error("Didn't default to format v1")
+	}
+}
+
+func TestFormatV2(t *testing.T) {
+	expr, _ := parser.ParseExpr("-a + true")
+	gotten, _ := NewDumper(token.NewFileSet(), Options{Format: FormatV2}).DumpExpr(expr)
+	if gotten["kind"] != "expression" || gotten["type"] != "binary" {
+		t.Error("Didn't dump a binary expression as an expression")
+	}
+
+	left := gotten["left"].(map[string]interface{})
+	if left["kind"] != "expression" || left["type"] != "unary" {
+		t.Error("Didn't dump a unary expression as an expression")
+	}
+
+	right := gotten["right"].(map[string]interface{})
+	if right["kind"] != "expression" || right["type"] != "identifier" {
+		t.Error("Didn't wrap a boolean in an identifier expression")
+	}
+
+	file := dumpFixture(t, "testdata/packages/helloworld/helloworld.go", Options{Format: FormatV2})
+	if file["format-version"] != float64(2) {
+		t.Error("Didn't report the format version")
+	}
+}
+
+func TestDocAndComment(t *testing.T) {
+	src := `package p
+
+// F is documented.
+func F() {}
+
+// T is documented.
+type T struct {
+	// A is documented.
+	A int // A is commented.
+}
+
+// V is documented.
+var V = 1 // V is commented.
+`
+	res := dumpSource(t, "doc.go", src, parser.ParseComments, Options{Format: FormatV2})
+	decls := res["declarations"].([]interface{})
+	fn := decls[0].(map[string]interface{})
+	if !reflect.DeepEqual(fn["doc"], []string{"// F is documented."}) || len(fn["comment"].([]string)) != 0 {
+		t.Error("Didn't dump a function's doc comment")
+	}
+	if _, ok := fn["comments"]; ok {
+		t.Error("Dumped a v1 \"comments\" key")
+	}
+
+	typ := decls[1].(map[string]interface{})
+	if !reflect.DeepEqual(typ["doc"], []string{"// T is documented."}) {
+		t.Error("Didn't dump a declaration's doc comment")
+	}
+	field := typ["specs"].([]interface{})[0].(map[string]interface{})["value"].(map[string]interface{})["fields"].([]map[string]interface{})[0]
+	if !reflect.DeepEqual(field["doc"], []string{"// A is documented."}) || !reflect.DeepEqual(field["comment"], []string{"// A is commented."}) {
+		t.Error("Didn't dump a field's comments")
+	}
+
+	spec := decls[2].(map[string]interface{})["specs"].([]interface{})[0].(map[string]interface{})
+	if !reflect.DeepEqual(spec["comment"], []string{"// V is commented."}) {
+		t.Error("Didn't dump a spec's trailing comment")
+	}
+
+	res = dumpSource(t, "doc.go", src, parser.ParseComments, Options{Format: FormatV1})
+	fn = res["declarations"].([]interface{})[0].(map[string]interface{})
+	if _, ok := fn["doc"]; ok || !reflect.DeepEqual(fn["comments"], []string{"// F is documented."}) {
+		t.Error("Didn't dump a v1 function's doc comment as \"comments\"")
+	}
+}
+
+func TestConcurrentOptions(t *testing.T) {
+	shapes := []OutputShape{CurrentShape, LegacyTypeAliases}
+	needed := []string{"type", "type-alias"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			gotten := dumpFixture(t, "testdata/packages/simpletypealias/simpletypealias.go", Options{Shape: shapes[i%2]})
+			decl := gotten["declarations"].([]interface{})[0].(map[string]interface{})
+			if decl["type"] != needed[i%2] {
+				t.Error("Options leaked between concurrent Dumpers")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNoPositions(t *testing.T) {
+	gotten := dumpFixture(t, "testdata/packages/helloworld/helloworld.go", Options{Positions: NoPositions})
+	name := gotten["name"].(map[string]interface{})
+	if name["position"].(map[string]interface{}) != nil {
+		t.Error("Reported a position despite NoPositions")
+	}
+}
+
+func TestErrorsAreReturned(t *testing.T) {
+	d := NewDumper(token.NewFileSet(), Options{})
+
+	_, err := d.DumpExpr(&ast.BadExpr{})
+	if e, ok := err.(*Error); !ok || e.Type != "internal_error" {
+		t.Error("Didn't return an error for a BadExpr")
+	}
+
+	_, err = d.DumpStmt(&ast.BadStmt{})
+	if e, ok := err.(*Error); !ok || e.Type != "internal_error" {
+		t.Error("Didn't return an error for a BadStmt")
+	}
+
+	for _, src := range []string{"new()", "make()", "[]int()", "[]int(a, b)"} {
+		expr, _ := parser.ParseExpr(src)
+		_, err = d.DumpExpr(expr)
+		if e, ok := err.(*Error); !ok || e.Type != "wrong_argument_count" {
+			t.Errorf("Didn't return an error for %s", src)
+		}
+	}
+
+	// A hand-built tree can be malformed in ways we don't check for.
+	_, err = d.DumpExpr(&ast.CallExpr{})
+	if e, ok := err.(*Error); !ok || e.Type != "internal_error" {
+		t.Error("Didn't turn a runtime panic into an error")
+	}
+
+	_, err = d.DumpExpr(&ast.Ident{Name: "fine"})
+	if err != nil {
+		t.Error("Returned an error for a well-formed expression")
+	}
+}
+
+func TestLegacyFunctions(t *testing.T) {
+	fset := token.NewFileSet()
+	expr, _ := parser.ParseExpr("f(x) + 1")
+	needed, _ := NewDumper(fset, Options{}).DumpExpr(expr)
+	if !reflect.DeepEqual(DumpExpr(expr, fset), needed) {
+		t.Error("DumpExpr doesn't match Dumper.DumpExpr")
+	}
+
+	defer func() {
+		if _, ok := recover().(*Error); !ok {
+			t.Error("DumpStmt didn't panic with an *Error")
+		}
+	}()
+	DumpStmt(&ast.BadStmt{}, fset)
+}
+
+func TestRanges(t *testing.T) {
+	gotten := dumpFixture(t, "testdata/packages/helloworld/helloworld.go", Options{})
+	if _, ok := gotten["name"].(map[string]interface{})["range"]; ok {
+		t.Error("Reported a range without being asked to")
+	}
+
+	gotten = dumpFixture(t, "testdata/packages/helloworld/helloworld.go", Options{Ranges: true})
+	main := gotten["declarations"].([]interface{})[0].(map[string]interface{})
+	rng := main["range"].(map[string]interface{})
+	start := rng["start"].(map[string]interface{})
+	end := rng["end"].(map[string]interface{})
+	if start["line"] != float64(3) || start["column"] != float64(1) || end["line"] != float64(5) || end["column"] != float64(2) {
+		t.Error("Reported the wrong range for a function declaration")
+	}
+
+	call := main["body"].([]interface{})[0].(map[string]interface{})["value"].(map[string]interface{})
+	end = call["range"].(map[string]interface{})["end"].(map[string]interface{})
+	if end["offset"] != float64(53) {
+		t.Error("Reported the wrong end offset for a call")
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lenient.go", "package p; func f() { a := new(1); b := make(2) }", 0)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	res, err := NewDumper(fset, Options{Errors: CollectErrors}).DumpFile(f)
+	if res == nil {
+		t.Fatal("Didn't produce any output in lenient mode")
+	}
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Didn't collect both unsupported nodes")
+	}
+
+	if errs[0].Type != "unrecognized_type" || errs[0].Position.Column != 32 {
+		t.Error("Collected the wrong error")
+	}
+
+	body := res["declarations"].([]interface{})[0].(map[string]interface{})["body"].([]interface{})
+	argument := body[0].(map[string]interface{})["right"].([]interface{})[0].(map[string]interface{})["argument"].(map[string]interface{})
+	if argument["kind"] != "unsupported" || argument["type"] != "*ast.BasicLit" {
+		t.Error("Didn't dump a placeholder for an unsupported node")
+	}
+
+	_, err = NewDumper(fset, Options{Errors: CollectErrors}).DumpStmt(&ast.BadStmt{})
+	if errs, ok := err.(ErrorList); !ok || len(errs) != 1 {
+		t.Error("Didn't collect a BadStmt")
+	}
+}
+
+func TestCollectErrorsCarriesOn(t *testing.T) {
+	src := "package p; func f(x interface{}) { switch x.(type) {}; a := new(); b := 1 }"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lenient.go", src, 0)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	res, err := NewDumper(fset, Options{Errors: CollectErrors}).DumpFile(f)
+	if res == nil {
+		t.Fatalf("Gave up in lenient mode: %v", err)
+	}
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Type != "wrong_argument_count" {
+		t.Fatalf("Collected %v rather than a single call with the wrong arguments", err)
+	}
+
+	body := res["declarations"].([]interface{})[0].(map[string]interface{})["body"].([]interface{})
+	call := body[1].(map[string]interface{})["right"].([]interface{})[0].(map[string]interface{})
+	if len(body) != 3 || call["kind"] != "unsupported" || call["type"] != "*ast.CallExpr" {
+		t.Error("Didn't dump a placeholder for new() and carry on")
+	}
+}
+
+func TestLenientMissingReceiver(t *testing.T) {
+	for _, src := range []string{
+		"package p\n\nfunc () M() {}\n\nfunc g() {}\n",
+		"package p\n\nfunc (",
+	} {
+		// The parser complains, but still gives us what it found.
+		fset := token.NewFileSet()
+		f, _ := parser.ParseFile(fset, "receiver.go", src, 0)
+
+		res, err := NewDumper(fset, Options{Errors: CollectErrors}).DumpFile(f)
+		if res == nil {
+			t.Errorf("Gave up on %q in lenient mode: %v", src, err)
+			continue
+		}
+		if err := checkSchema(DefaultFormat, res); err != nil {
+			t.Errorf("%q: %v", src, err)
+		}
+
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 || errs[0].Type != "missing_receiver" {
+			t.Errorf("Collected %v for %q rather than a missing receiver", err, src)
+		}
+
+		method := res["declarations"].([]interface{})[0].(map[string]interface{})
+		declared := method["receiver"].(map[string]interface{})["declared-type"].(map[string]interface{})
+		if declared["kind"] != "unsupported" {
+			t.Errorf("Dumped the receiver of %q as %v", src, declared)
+		}
+	}
+}
+
+// every node with a "kind" must say where it came from.
+func checkPositions(t *testing.T, where string, node interface{}) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if _, ok := n["kind"]; ok {
+			if _, ok := n["position"]; !ok {
+				t.Errorf("%s: %s/%s node has no position", where, n["kind"], n["type"])
+			}
+		}
+
+		for _, v := range n {
+			checkPositions(t, where, v)
+		}
+
+	case []interface{}:
+		for _, v := range n {
+			checkPositions(t, where, v)
+		}
+	}
+}
+
+func TestEveryNodeHasAPosition(t *testing.T) {
+	packages, _ := filepath.Glob("testdata/packages/*/*.go")
+	for _, p := range packages {
+		var gotten interface{}
+		json.Unmarshal(TestFile(p), &gotten)
+		checkPositions(t, p, gotten)
+	}
+
+	expressions, _ := filepath.Glob("testdata/expressions/*/*.go.txt")
+	for _, p := range expressions {
+		text, _ := ioutil.ReadFile(p)
+		var gotten interface{}
+		res, _ := json.Marshal(TestExpr(string(text)))
+		json.Unmarshal(res, &gotten)
+		checkPositions(t, p, gotten)
+	}
+}
+
 func TestIota(t *testing.T) {
 	gotten := TestExpr("iota")
 	val := gotten["value"].(map[string]interface{})
@@ -210,14 +651,83 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestShadowedBuiltins(t *testing.T) {
+	src := `package p
+
+func new(n int) int { return n }
+
+func f(make func(int) []int, s []int) {
+	_ = new(1)
+	_ = make(2)
+	_ = len(s)
+	len := func(int) int { return 0 }
+	_ = len(3)
+}
+`
+	for _, opts := range []Options{{}, {Types: typeCheck}, {Format: FormatV2}} {
+		res := dumpSource(t, "shadow.go", src, 0, opts)
+		body := res["declarations"].([]interface{})[1].(map[string]interface{})["body"].([]interface{})
+		calls := []map[string]interface{}{}
+		for _, i := range []int{0, 1, 2, 4} {
+			calls = append(calls, body[i].(map[string]interface{})["right"].([]interface{})[0].(map[string]interface{}))
+		}
+
+		if calls[0]["type"] != "call" || calls[1]["type"] != "call" || calls[0]["builtin"] != nil {
+			t.Error("Dumped a shadowed new or make as the builtin")
+		}
+		if calls[2]["builtin"] != true || calls[3]["builtin"] != nil {
+			t.Error("Didn't tell the builtin len from a shadowing one")
+		}
+	}
+
+	// Declarations in another file of the package count too.
+	fset := token.NewFileSet()
+	other, _ := parser.ParseFile(fset, "other.go", "package p\n\nfunc g() int { return cap(nil) }\nfunc cap(interface{}) int { return 0 }\n", 0)
+	main, _ := parser.ParseFile(fset, "main.go", "package p\n\nfunc h() int { return cap(nil) }\n", 0)
+	res, _ := NewDumper(fset, Options{}).DumpPackage(&build.Package{Name: "p"}, []*ast.File{other, main})
+	file := res["files"].([]interface{})[1].(map[string]interface{})
+	ret := file["declarations"].([]interface{})[0].(map[string]interface{})["body"].([]interface{})[0].(map[string]interface{})
+	if ret["values"].([]interface{})[0].(map[string]interface{})["builtin"] != nil {
+		t.Error("Took a function declared elsewhere in the package for a builtin")
+	}
+}
+
 func TestRoundTripUInt(t *testing.T) {
 	f := func(int uint64) bool {
 		needed := fmt.Sprintf("%d", int)
 		gotten := TestExpr(needed)
-		return needed == gotten["value"]
+		return needed == gotten["value"] && checkSchema(DefaultFormat, gotten) == nil
 	}
 
 	if err := quick.Check(f, nil); err != nil {
 		t.Error(err)
 	}
 }
+
+func checkUnmarshal(t *testing.T, where string, dumped interface{}) {
+	want, _ := json.Marshal(dumped)
+	node, err := Unmarshal(want)
+	if err != nil {
+		t.Errorf("%s: %v", where, err)
+		return
+	}
+
+	got, _ := json.Marshal(node)
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: typed nodes don't marshal back to the same JSON", where)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	// The package fixtures are checked by TestFixturesMatchSchema, as
+	// dumpFixture unmarshals every dump.
+	expressions, _ := filepath.Glob("testdata/expressions/*/*.go.txt")
+	for _, p := range expressions {
+		text, _ := ioutil.ReadFile(p)
+		checkUnmarshal(t, p, TestExpr(string(text)))
+	}
+
+	if _, err := Unmarshal([]byte(`{"kind": "nonsense"}`)); err == nil {
+		t.Error("Didn't reject an unrecognized node")
+	}
+}
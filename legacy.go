@@ -0,0 +1,147 @@
+package goblin
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/token"
+	"os"
+)
+
+// The package-level functions below are what goblin offered before Dumper.
+// They're kept so that existing callers still build. Rather than exiting the
+// process on error, as they used to, they panic with an *Error.
+
+func legacy(fset *token.FileSet) *Dumper {
+	return NewDumper(fset, Options{Errors: PanicOnError})
+}
+
+// Perish writes an error to stderr as JSON and exits the process.
+//
+// Deprecated: Dumper methods return an *Error instead.
+func Perish(pos token.Position, typ string, reason string) {
+	res, _ := json.Marshal(map[string]interface{}{
+		"error": (&Error{Type: typ, Info: reason, Position: pos}).Dump(),
+	})
+	os.Stderr.Write(res)
+	os.Exit(1)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpIdent(i *ast.Ident, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpIdent(i)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpArray(a *ast.ArrayType, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpArray(a)
+}
+
+// AttemptExprAsType dumps e as a type, returning nil if it isn't one.
+//
+// Deprecated: use Dumper.DumpExpr.
+func AttemptExprAsType(e ast.Expr, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).attemptExprAsType(e)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpExprAsType(e ast.Expr, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpExprAsType(e)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpChanDir(d ast.ChanDir) string {
+	return legacy(nil).dumpChanDir(d)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpExpr(e ast.Expr, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpExpr(e)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpExprs(exprs []ast.Expr, fset *token.FileSet) []interface{} {
+	return legacy(fset).dumpExprs(exprs)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpBinaryExpr(b *ast.BinaryExpr, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpBinaryExpr(b)
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpBasicLit(l *ast.BasicLit, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpBasicLit(l)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpField(f *ast.Field, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpField(f)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpFields(fs *ast.FieldList, fset *token.FileSet) []map[string]interface{} {
+	return legacy(fset).dumpFields(fs)
+}
+
+// Deprecated: use Dumper.DumpFile.
+func DumpCommentGroup(g *ast.CommentGroup, fset *token.FileSet) []string {
+	return legacy(fset).dumpCommentGroup(g)
+}
+
+// DumpTypeAlias dumps t as a "type-alias" declaration, as the
+// LegacyTypeAliases shape does.
+//
+// Deprecated: use Dumper.DumpDecl.
+func DumpTypeAlias(t *ast.TypeSpec, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpTypeAlias(&ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{t}})
+}
+
+// Deprecated: use Dumper.DumpExpr.
+func DumpCall(c *ast.CallExpr, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpCall(c)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpImport(spec *ast.ImportSpec, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpImport(spec)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpValue(kind string, spec *ast.ValueSpec, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpValue(kind, spec)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpGenDecl(decl *ast.GenDecl, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpGenDecl(decl)
+}
+
+// Deprecated: use Dumper.DumpStmt.
+func DumpStmt(s ast.Stmt, fset *token.FileSet) interface{} {
+	return legacy(fset).dumpStmt(s)
+}
+
+// Deprecated: use Dumper.DumpStmts.
+func DumpBlock(b *ast.BlockStmt, fset *token.FileSet) []interface{} {
+	return legacy(fset).dumpBlock(b)
+}
+
+// Deprecated: use Dumper.DumpStmt.
+func DumpBlockAsStmt(b *ast.BlockStmt, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpBlockAsStmt(b)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpFuncDecl(f *ast.FuncDecl, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpFuncDecl(f)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpMethodDecl(f *ast.FuncDecl, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpMethodDecl(f)
+}
+
+// Deprecated: use Dumper.DumpDecl.
+func DumpDecl(n ast.Decl, fset *token.FileSet) map[string]interface{} {
+	return legacy(fset).dumpDecl(n)
+}
@@ -0,0 +1,200 @@
+package goblin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Module is a Go module on disk. Goblin never downloads anything: packages
+// are looked for in the module's own tree and in its vendor directory.
+type Module struct {
+	// Path is the module path declared in go.mod.
+	Path string
+	// Dir is the directory holding go.mod.
+	Dir string
+}
+
+// PackageDir is a directory holding a package, and the path it's imported by.
+type PackageDir struct {
+	ImportPath string
+	Dir        string
+}
+
+// FindModule finds the module containing dir, by looking for a go.mod in dir
+// and then in each of its parents.
+func FindModule(dir string) (*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		text, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			p := modulePath(text)
+			if p == "" {
+				return nil, fmt.Errorf("%s: no module directive", filepath.Join(dir, "go.mod"))
+			}
+			return &Module{Path: p, Dir: dir}, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no go.mod found")
+		}
+		dir = parent
+	}
+}
+
+// modulePath pulls the module path out of the text of a go.mod file.
+func modulePath(gomod []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(gomod))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+
+		if p, err := strconv.Unquote(fields[1]); err == nil {
+			return p
+		}
+		return fields[1]
+	}
+
+	return ""
+}
+
+// importPath works out the import path of a directory inside the module,
+// treating anything under vendor/ as the package it was vendored from.
+func (m *Module) importPath(dir string) (string, error) {
+	rel, err := filepath.Rel(m.Dir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside module %s", dir, m.Path)
+	}
+
+	rel = filepath.ToSlash(rel)
+	switch {
+	case rel == ".":
+		return m.Path, nil
+	case strings.HasPrefix(rel, "vendor/"):
+		return strings.TrimPrefix(rel, "vendor/"), nil
+	}
+
+	return path.Join(m.Path, rel), nil
+}
+
+// dir finds the directory of the package imported as importPath, which
+// must belong to the module or have been vendored into it.
+func (m *Module) dir(importPath string) (string, error) {
+	if importPath == m.Path {
+		return m.Dir, nil
+	}
+
+	if strings.HasPrefix(importPath, m.Path+"/") {
+		return filepath.Join(m.Dir, filepath.FromSlash(strings.TrimPrefix(importPath, m.Path+"/"))), nil
+	}
+
+	vendored := filepath.Join(m.Dir, "vendor", filepath.FromSlash(importPath))
+	if info, err := os.Stat(vendored); err == nil && info.IsDir() {
+		return vendored, nil
+	}
+
+	return "", fmt.Errorf("package %s is neither in module %s nor in its vendor directory", importPath, m.Path)
+}
+
+// Match expands a pattern into the packages it names, much like the go
+// tool does. A pattern is either a directory (".", "./x", "../y" or an
+// absolute path) or an import path, and a trailing "/..." makes it match
+// every package below it as well. Like the go tool, "/..." skips testdata
+// directories, directories whose names start with "." or "_", vendor
+// directories and nested modules. ctxt decides which files count, as in
+// ParsePackage.
+func (m *Module) Match(ctxt *build.Context, pattern string) ([]PackageDir, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+
+	recursive := pattern == "..." || strings.HasSuffix(pattern, "/...")
+	base := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+	if base == "" {
+		base = "."
+	}
+
+	var root string
+	if base == "." || base == ".." || strings.HasPrefix(base, "./") || strings.HasPrefix(base, "../") || filepath.IsAbs(base) {
+		abs, err := filepath.Abs(base)
+		if err != nil {
+			return nil, err
+		}
+		root = abs
+	} else {
+		dir, err := m.dir(base)
+		if err != nil {
+			return nil, err
+		}
+		root = dir
+	}
+
+	if !recursive {
+		p, err := m.importPath(root)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ctxt.ImportDir(root, 0); err != nil {
+			return nil, err
+		}
+		return []PackageDir{{p, root}}, nil
+	}
+
+	var result []PackageDir
+	err := filepath.WalkDir(root, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if dir != root {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		if _, err := ctxt.ImportDir(dir, 0); err != nil {
+			if _, ok := err.(*build.NoGoError); ok {
+				return nil
+			}
+			return err
+		}
+
+		p, err := m.importPath(dir)
+		if err != nil {
+			return err
+		}
+		result = append(result, PackageDir{p, dir})
+		return nil
+	})
+
+	return result, err
+}
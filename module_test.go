@@ -0,0 +1,83 @@
+package goblin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeModule(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, text := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestFindModule(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"go.mod":   "// a comment\nmodule \"example.com/m\" // another\n\ngo 1.21\n",
+		"sub/s.go": "package sub\n",
+	})
+
+	m, err := FindModule(filepath.Join(root, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Path != "example.com/m" || m.Dir != root {
+		t.Errorf("Found module %s in %s", m.Path, m.Dir)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"go.mod":                      "module example.com/m\n",
+		"m.go":                        "package m\n",
+		"sub/s.go":                    "package sub\n",
+		"sub/deeper/d.go":             "package deeper\n",
+		"sub/ignored/i.go":            "//go:build ignore\n\npackage ignored\n",
+		"testdata/t.go":               "package t\n",
+		"_hidden/h.go":                "package h\n",
+		"nested/go.mod":               "module example.com/nested\n",
+		"nested/n.go":                 "package nested\n",
+		"vendor/example.org/dep/d.go": "package dep\n",
+	})
+	m := &Module{Path: "example.com/m", Dir: root}
+
+	cases := map[string][]string{
+		root + "/...":                    {"example.com/m", "example.com/m/sub", "example.com/m/sub/deeper"},
+		"example.com/m/sub/...":          {"example.com/m/sub", "example.com/m/sub/deeper"},
+		"example.com/m/sub":              {"example.com/m/sub"},
+		"example.org/dep":                {"example.org/dep"},
+		root + "/vendor/example.org/...": {"example.org/dep"},
+	}
+
+	for pattern, wanted := range cases {
+		matched, err := m.Match(nil, pattern)
+		if err != nil {
+			t.Errorf("%s: %v", pattern, err)
+			continue
+		}
+
+		var gotten []string
+		for _, p := range matched {
+			gotten = append(gotten, p.ImportPath)
+		}
+		if !reflect.DeepEqual(gotten, wanted) {
+			t.Errorf("%s matched %v, wanted %v", pattern, gotten, wanted)
+		}
+	}
+
+	if _, err := m.Match(nil, "example.org/missing"); err == nil {
+		t.Error("Didn't complain about a package that isn't in the module")
+	}
+}
@@ -0,0 +1,1043 @@
+package goblin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The types below mirror the JSON a Dumper produces, one struct per node
+// shape. Their fields are declared in the order encoding/json sorts map
+// keys, so marshalling one of them gives the same bytes as marshalling the
+// map it was decoded from. Fields that can hold more than one kind of node
+// are of type Node; use a type switch to get at them. Schema is derived from
+// these types too, and fields tagged `schema:"nullable"` are the ones it
+// allows to be null. Fields tagged `schema:"v1"` or `schema:"v2"` only exist
+// in that format version.
+
+// Node is implemented by every node type in this file.
+type Node interface {
+	node()
+}
+
+// Position is the JSON form of a token.Position.
+type Position struct {
+	Column   int    `json:"column"`
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Offset   int    `json:"offset"`
+}
+
+// Range is only present when Options.Ranges is set.
+type Range struct {
+	End   *Position `json:"end"`
+	Start *Position `json:"start"`
+}
+
+// TypeAnnotation is only present when Options.Types is set, and only on
+// expressions go/types knows about.
+type TypeAnnotation struct {
+	Object string `json:"object,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+// Ident is an identifier, as found in names and labels.
+type Ident struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Value    string          `json:"value"`
+}
+
+// BasicLit is a literal; IOTA literals have no value.
+type BasicLit struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Value    string          `json:"value,omitempty"`
+}
+
+// FuncLit is a function literal.
+type FuncLit struct {
+	Body       []Node          `json:"body"`
+	Kind       string          `json:"kind"`
+	Params     []*Field        `json:"params"`
+	Position   *Position       `json:"position"`
+	Range      *Range          `json:"range,omitempty"`
+	Results    []*Field        `json:"results" schema:"nullable"`
+	Type       string          `json:"type"`
+	TypeParams []*Field        `json:"type-params" schema:"nullable"`
+	Types      *TypeAnnotation `json:"types,omitempty"`
+}
+
+// CompositeLit is a composite literal; Declared is nil when the type is implied.
+type CompositeLit struct {
+	Declared Node            `json:"declared" schema:"nullable"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Values   []Node          `json:"values"`
+}
+
+// Unsupported stands in for a node that couldn't be dumped in lenient mode.
+type Unsupported struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+// IdentifierType is a possibly qualified type name.
+type IdentifierType struct {
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Qualifier Node            `json:"qualifier,omitempty"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+	Value     Node            `json:"value"`
+}
+
+type SliceType struct {
+	Element  Node            `json:"element"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type ArrayType struct {
+	Element  Node            `json:"element"`
+	Kind     string          `json:"kind"`
+	Length   Node            `json:"length"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+// EllipsisType is the `...` length of an array literal, which has no Value, or
+// the type of a variadic parameter, whose Value is the type of each argument.
+type EllipsisType struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Value    Node            `json:"value" schema:"nullable"`
+}
+
+type PointerType struct {
+	Contained Node            `json:"contained"`
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+}
+
+type InterfaceType struct {
+	Embedded   []Node          `json:"embedded"`
+	Incomplete bool            `json:"incomplete"`
+	Kind       string          `json:"kind"`
+	Methods    []*Field        `json:"methods"`
+	Position   *Position       `json:"position"`
+	Range      *Range          `json:"range,omitempty"`
+	Type       string          `json:"type"`
+	TypeSet    []Node          `json:"type-set"`
+	Types      *TypeAnnotation `json:"types,omitempty"`
+}
+
+type UnionType struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Terms    []Node          `json:"terms"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type TermType struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Tilde    bool            `json:"tilde"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Value    Node            `json:"value"`
+}
+
+type MapType struct {
+	Key      Node            `json:"key"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Value    Node            `json:"value"`
+}
+
+type ChanType struct {
+	Direction string          `json:"direction"`
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+	Value     Node            `json:"value"`
+}
+
+type StructType struct {
+	Fields   []*Field        `json:"fields"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type FuncType struct {
+	Kind     string          `json:"kind"`
+	Params   []*Field        `json:"params"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Results  []*Field        `json:"results" schema:"nullable"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type InstantiationType struct {
+	Arguments []Node          `json:"arguments"`
+	Generic   Node            `json:"generic"`
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+}
+
+// IdentifierExpr is a possibly qualified identifier.
+type IdentifierExpr struct {
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Qualifier Node            `json:"qualifier,omitempty"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+	Value     Node            `json:"value"`
+}
+
+type IndexExpr struct {
+	Index    Node            `json:"index"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type InstantiationExpr struct {
+	Arguments []Node          `json:"arguments"`
+	Generic   Node            `json:"generic"`
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+}
+
+type StarExpr struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type ParenExpr struct {
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type SelectorExpr struct {
+	Field    Node            `json:"field"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type TypeAssertExpr struct {
+	Asserted Node            `json:"asserted" schema:"nullable"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type SliceExpr struct {
+	High     Node            `json:"high" schema:"nullable"`
+	Kind     string          `json:"kind"`
+	Low      Node            `json:"low" schema:"nullable"`
+	Max      Node            `json:"max" schema:"nullable"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Three    bool            `json:"three"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type KeyValueExpr struct {
+	Key      Node            `json:"key"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+	Value    Node            `json:"value"`
+}
+
+// CallExpr is a call; Builtin is set for calls to built-in functions.
+type CallExpr struct {
+	Arguments []Node          `json:"arguments"`
+	Builtin   bool            `json:"builtin,omitempty"`
+	Ellipsis  bool            `json:"ellipsis"`
+	Function  Node            `json:"function"`
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Range     *Range          `json:"range,omitempty"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+}
+
+type CastExpr struct {
+	CoercedTo Node            `json:"coerced-to"`
+	Kind      string          `json:"kind"`
+	Position  *Position       `json:"position"`
+	Range     *Range          `json:"range,omitempty"`
+	Target    Node            `json:"target"`
+	Type      string          `json:"type"`
+	Types     *TypeAnnotation `json:"types,omitempty"`
+}
+
+type NewExpr struct {
+	Argument Node            `json:"argument"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type MakeExpr struct {
+	Argument Node            `json:"argument"`
+	Kind     string          `json:"kind"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Rest     []Node          `json:"rest"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type UnaryExpr struct {
+	Kind     string          `json:"kind"`
+	Operator string          `json:"operator"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Target   Node            `json:"target"`
+	Type     string          `json:"type,omitempty"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+type BinaryExpr struct {
+	Kind     string          `json:"kind"`
+	Left     Node            `json:"left"`
+	Operator string          `json:"operator"`
+	Position *Position       `json:"position"`
+	Range    *Range          `json:"range,omitempty"`
+	Right    Node            `json:"right"`
+	Type     string          `json:"type"`
+	Types    *TypeAnnotation `json:"types,omitempty"`
+}
+
+// Field is a struct field, a parameter, a result or an interface method.
+type Field struct {
+	Comment      []string  `json:"comment,omitzero" schema:"v2"`
+	DeclaredType Node      `json:"declared-type" schema:"nullable"`
+	Doc          []string  `json:"doc,omitzero" schema:"v2"`
+	Kind         string    `json:"kind"`
+	Names        []Node    `json:"names"`
+	Position     *Position `json:"position"`
+	Range        *Range    `json:"range,omitempty"`
+	Tag          Node      `json:"tag" schema:"nullable"`
+}
+
+type FuncDecl struct {
+	Body       []Node       `json:"body" schema:"nullable"`
+	Comment    []string     `json:"comment,omitzero" schema:"v2"`
+	Comments   []string     `json:"comments,omitzero" schema:"v1"`
+	Directives []*Directive `json:"directives"`
+	Doc        []string     `json:"doc,omitzero" schema:"v2"`
+	Kind       string       `json:"kind"`
+	Name       Node         `json:"name"`
+	Params     []*Field     `json:"params"`
+	Position   *Position    `json:"position"`
+	Range      *Range       `json:"range,omitempty"`
+	Results    []*Field     `json:"results" schema:"nullable"`
+	Type       string       `json:"type"`
+	TypeParams []*Field     `json:"type-params" schema:"nullable"`
+}
+
+type MethodDecl struct {
+	Body       []Node       `json:"body" schema:"nullable"`
+	Comment    []string     `json:"comment,omitzero" schema:"v2"`
+	Comments   []string     `json:"comments,omitzero" schema:"v1"`
+	Directives []*Directive `json:"directives"`
+	Doc        []string     `json:"doc,omitzero" schema:"v2"`
+	Kind       string       `json:"kind"`
+	Name       Node         `json:"name"`
+	Params     []*Field     `json:"params"`
+	Position   *Position    `json:"position"`
+	Range      *Range       `json:"range,omitempty"`
+	Receiver   *Field       `json:"receiver"`
+	Results    []*Field     `json:"results" schema:"nullable"`
+	Type       string       `json:"type"`
+	TypeParams []*Field     `json:"type-params" schema:"nullable"`
+}
+
+// TypeAliasDecl is the legacy shape of a type declaration.
+type TypeAliasDecl struct {
+	Alias      bool      `json:"alias"`
+	Comment    []string  `json:"comment,omitzero" schema:"v2"`
+	Comments   []string  `json:"comments,omitzero" schema:"v1"`
+	Doc        []string  `json:"doc,omitzero" schema:"v2"`
+	Kind       string    `json:"kind"`
+	Name       Node      `json:"name"`
+	Position   *Position `json:"position"`
+	Range      *Range    `json:"range,omitempty"`
+	Type       string    `json:"type"`
+	TypeParams []*Field  `json:"type-params" schema:"nullable"`
+	Value      Node      `json:"value"`
+}
+
+type GenDecl struct {
+	Comment  []string  `json:"comment,omitzero" schema:"v2"`
+	Doc      []string  `json:"doc,omitzero" schema:"v2"`
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Specs    []Node    `json:"specs"`
+	Type     string    `json:"type"`
+}
+
+type TypeSpec struct {
+	Alias      bool      `json:"alias"`
+	Comment    []string  `json:"comment,omitzero" schema:"v2"`
+	Comments   []string  `json:"comments,omitzero" schema:"v1"`
+	Doc        []string  `json:"doc,omitzero" schema:"v2"`
+	Kind       string    `json:"kind"`
+	Name       Node      `json:"name"`
+	Position   *Position `json:"position"`
+	Range      *Range    `json:"range,omitempty"`
+	Type       string    `json:"type"`
+	TypeParams []*Field  `json:"type-params" schema:"nullable"`
+	Value      Node      `json:"value"`
+}
+
+type ValueSpec struct {
+	Comment      []string  `json:"comment,omitzero" schema:"v2"`
+	Comments     []string  `json:"comments,omitzero" schema:"v1"`
+	DeclaredType Node      `json:"declared-type" schema:"nullable"`
+	Doc          []string  `json:"doc,omitzero" schema:"v2"`
+	Kind         string    `json:"kind"`
+	Names        []Node    `json:"names"`
+	Position     *Position `json:"position"`
+	Range        *Range    `json:"range,omitempty"`
+	Type         string    `json:"type"`
+	Values       []Node    `json:"values"`
+}
+
+// ImportSpec has no kind, only a type.
+type ImportSpec struct {
+	Comment  []string  `json:"comment,omitzero" schema:"v2"`
+	Comments []string  `json:"comments,omitzero" schema:"v1"`
+	Doc      []string  `json:"doc"`
+	Kind     string    `json:"kind,omitempty"`
+	Name     Node      `json:"name" schema:"nullable"`
+	Path     string    `json:"path"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+}
+
+type ReturnStmt struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+	Values   []Node    `json:"values"`
+}
+
+type AssignStmt struct {
+	Kind     string    `json:"kind"`
+	Left     []Node    `json:"left"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Right    []Node    `json:"right"`
+	Type     string    `json:"type"`
+}
+
+type AssignOperatorStmt struct {
+	Kind     string    `json:"kind"`
+	Left     []Node    `json:"left"`
+	Operator string    `json:"operator"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Right    []Node    `json:"right"`
+	Type     string    `json:"type"`
+}
+
+type EmptyStmt struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+}
+
+type ExprStmt struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+	Value    Node      `json:"value"`
+}
+
+type LabeledStmt struct {
+	Kind      string    `json:"kind"`
+	Label     Node      `json:"label"`
+	Position  *Position `json:"position"`
+	Range     *Range    `json:"range,omitempty"`
+	Statement Node      `json:"statement"`
+	Type      string    `json:"type"`
+}
+
+type BranchStmt struct {
+	Kind     string    `json:"kind"`
+	Label    Node      `json:"label" schema:"nullable"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+}
+
+type RangeStmt struct {
+	Body     []Node    `json:"body"`
+	IsAssign bool      `json:"is-assign"`
+	Key      Node      `json:"key" schema:"nullable"`
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Target   Node      `json:"target"`
+	Type     string    `json:"type"`
+	Value    Node      `json:"value" schema:"nullable"`
+}
+
+type DeclStmt struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Target   Node      `json:"target"`
+	Type     string    `json:"type"`
+}
+
+type DeferStmt struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Target   Node      `json:"target"`
+	Type     string    `json:"type"`
+}
+
+type IfStmt struct {
+	Body      []Node    `json:"body"`
+	Condition Node      `json:"condition"`
+	Else      Node      `json:"else" schema:"nullable"`
+	Init      Node      `json:"init" schema:"nullable"`
+	Kind      string    `json:"kind"`
+	Position  *Position `json:"position"`
+	Range     *Range    `json:"range,omitempty"`
+	Type      string    `json:"type"`
+}
+
+type BlockStmt struct {
+	Body     []Node    `json:"body"`
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+}
+
+type ForStmt struct {
+	Body      []Node    `json:"body"`
+	Condition Node      `json:"condition" schema:"nullable"`
+	Init      Node      `json:"init" schema:"nullable"`
+	Kind      string    `json:"kind"`
+	Position  *Position `json:"position"`
+	Post      Node      `json:"post" schema:"nullable"`
+	Range     *Range    `json:"range,omitempty"`
+	Type      string    `json:"type"`
+}
+
+type SendStmt struct {
+	Channel  Node      `json:"channel"`
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+	Value    Node      `json:"value"`
+}
+
+type IncDecStmt struct {
+	Kind      string    `json:"kind"`
+	Operation string    `json:"operation"`
+	Position  *Position `json:"position"`
+	Range     *Range    `json:"range,omitempty"`
+	Target    Node      `json:"target"`
+	Type      string    `json:"type"`
+}
+
+type SwitchStmt struct {
+	Body      []Node    `json:"body"`
+	Condition Node      `json:"condition" schema:"nullable"`
+	Init      Node      `json:"init" schema:"nullable"`
+	Kind      string    `json:"kind"`
+	Position  *Position `json:"position"`
+	Range     *Range    `json:"range,omitempty"`
+	Type      string    `json:"type"`
+}
+
+type TypeSwitchStmt struct {
+	Assign   Node      `json:"assign"`
+	Body     []Node    `json:"body"`
+	Init     Node      `json:"init" schema:"nullable"`
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Type     string    `json:"type"`
+}
+
+type CommClause struct {
+	Body      []Node    `json:"body"`
+	Kind      string    `json:"kind"`
+	Position  *Position `json:"position"`
+	Range     *Range    `json:"range,omitempty"`
+	Statement Node      `json:"statement" schema:"nullable"`
+	Type      string    `json:"type"`
+}
+
+type CaseClause struct {
+	Body        []Node    `json:"body"`
+	Expressions []Node    `json:"expressions"`
+	Kind        string    `json:"kind"`
+	Position    *Position `json:"position"`
+	Range       *Range    `json:"range,omitempty"`
+	Type        string    `json:"type"`
+}
+
+type File struct {
+	AllComments     []*CommentGroup `json:"all-comments"`
+	BuildConstraint Node            `json:"build-constraint,omitempty"`
+	Comments        []string        `json:"comments"`
+	Declarations    []Node          `json:"declarations"`
+	Directives      []*Directive    `json:"directives"`
+	FormatVersion   int             `json:"format-version"`
+	Imports         []Node          `json:"imports"`
+	Kind            string          `json:"kind"`
+	Name            Node            `json:"name"`
+	Position        *Position       `json:"position"`
+	Range           *Range          `json:"range,omitempty"`
+}
+
+// CommentGroup is an entry of a file's "all-comments". Node is the path of
+// the node it's attached to. Format version 1 dumps a group as nothing but
+// the text of its comments, which is kept in Lines.
+type CommentGroup struct {
+	Comments []*Comment `json:"comments"`
+	End      *Position  `json:"end"`
+	Kind     string     `json:"kind"`
+	Node     string     `json:"node"`
+	Position *Position  `json:"position"`
+	Range    *Range     `json:"range,omitempty"`
+	Lines    []string   `json:"-"`
+}
+
+// MarshalJSON writes g in whichever format version it was read in.
+func (g *CommentGroup) MarshalJSON() ([]byte, error) {
+	if g.Kind == "" {
+		return json.Marshal(g.Lines)
+	}
+
+	type plain CommentGroup
+	return json.Marshal((*plain)(g))
+}
+
+// Directive is a comment such as //go:generate or //export. Tool is empty
+// for the latter.
+type Directive struct {
+	Arguments string    `json:"arguments"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Position  *Position `json:"position"`
+	Range     *Range    `json:"range,omitempty"`
+	Tool      string    `json:"tool"`
+}
+
+// BinaryConstraint is an "and" or an "or" in a build constraint.
+type BinaryConstraint struct {
+	Kind     string    `json:"kind"`
+	Left     Node      `json:"left"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Right    Node      `json:"right"`
+	Type     string    `json:"type"`
+}
+
+type NotConstraint struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Target   Node      `json:"target"`
+	Type     string    `json:"type"`
+}
+
+type TagConstraint struct {
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Tag      string    `json:"tag"`
+	Type     string    `json:"type"`
+}
+
+type Comment struct {
+	End      *Position `json:"end"`
+	Kind     string    `json:"kind"`
+	Position *Position `json:"position"`
+	Range    *Range    `json:"range,omitempty"`
+	Text     string    `json:"text"`
+	Type     string    `json:"type"`
+}
+
+// Package holds the dumps of several files making up one package. Its
+// position is that of the first file's package clause, and it has no range.
+type Package struct {
+	Files         []*File   `json:"files"`
+	FormatVersion int       `json:"format-version"`
+	ImportPath    string    `json:"import-path,omitempty"`
+	Imports       []string  `json:"imports"`
+	Kind          string    `json:"kind"`
+	Name          string    `json:"name"`
+	Position      *Position `json:"position"`
+	Range         *Range    `json:"range,omitempty"`
+}
+
+func (*Ident) node()              {}
+func (*BasicLit) node()           {}
+func (*FuncLit) node()            {}
+func (*CompositeLit) node()       {}
+func (*Unsupported) node()        {}
+func (*IdentifierType) node()     {}
+func (*SliceType) node()          {}
+func (*ArrayType) node()          {}
+func (*EllipsisType) node()       {}
+func (*PointerType) node()        {}
+func (*InterfaceType) node()      {}
+func (*UnionType) node()          {}
+func (*TermType) node()           {}
+func (*MapType) node()            {}
+func (*ChanType) node()           {}
+func (*StructType) node()         {}
+func (*FuncType) node()           {}
+func (*InstantiationType) node()  {}
+func (*IdentifierExpr) node()     {}
+func (*IndexExpr) node()          {}
+func (*InstantiationExpr) node()  {}
+func (*StarExpr) node()           {}
+func (*ParenExpr) node()          {}
+func (*SelectorExpr) node()       {}
+func (*TypeAssertExpr) node()     {}
+func (*SliceExpr) node()          {}
+func (*KeyValueExpr) node()       {}
+func (*CallExpr) node()           {}
+func (*CastExpr) node()           {}
+func (*NewExpr) node()            {}
+func (*MakeExpr) node()           {}
+func (*UnaryExpr) node()          {}
+func (*BinaryExpr) node()         {}
+func (*Field) node()              {}
+func (*FuncDecl) node()           {}
+func (*MethodDecl) node()         {}
+func (*TypeAliasDecl) node()      {}
+func (*GenDecl) node()            {}
+func (*TypeSpec) node()           {}
+func (*ValueSpec) node()          {}
+func (*ImportSpec) node()         {}
+func (*ReturnStmt) node()         {}
+func (*AssignStmt) node()         {}
+func (*AssignOperatorStmt) node() {}
+func (*EmptyStmt) node()          {}
+func (*ExprStmt) node()           {}
+func (*LabeledStmt) node()        {}
+func (*BranchStmt) node()         {}
+func (*RangeStmt) node()          {}
+func (*DeclStmt) node()           {}
+func (*DeferStmt) node()          {}
+func (*IfStmt) node()             {}
+func (*BlockStmt) node()          {}
+func (*ForStmt) node()            {}
+func (*SendStmt) node()           {}
+func (*IncDecStmt) node()         {}
+func (*SwitchStmt) node()         {}
+func (*TypeSwitchStmt) node()     {}
+func (*CommClause) node()         {}
+func (*CaseClause) node()         {}
+func (*Package) node()            {}
+func (*File) node()               {}
+func (*CommentGroup) node()       {}
+func (*Comment) node()            {}
+func (*Directive) node()          {}
+func (*BinaryConstraint) node()   {}
+func (*NotConstraint) node()      {}
+func (*TagConstraint) node()      {}
+
+// nodeTypes maps the "kind" and "type" of a node to the Go type it decodes
+// into. An empty "type" means the node has none, and "*" means it can be
+// anything.
+var nodeTypes = map[[2]string]reflect.Type{
+	{"ident", ""}:                    reflect.TypeOf(Ident{}),
+	{"literal", "INT"}:               reflect.TypeOf(BasicLit{}),
+	{"literal", "FLOAT"}:             reflect.TypeOf(BasicLit{}),
+	{"literal", "IMAG"}:              reflect.TypeOf(BasicLit{}),
+	{"literal", "CHAR"}:              reflect.TypeOf(BasicLit{}),
+	{"literal", "STRING"}:            reflect.TypeOf(BasicLit{}),
+	{"literal", "BOOL"}:              reflect.TypeOf(BasicLit{}),
+	{"literal", "IOTA"}:              reflect.TypeOf(BasicLit{}),
+	{"literal", "function"}:          reflect.TypeOf(FuncLit{}),
+	{"literal", "composite"}:         reflect.TypeOf(CompositeLit{}),
+	{"unsupported", "*"}:             reflect.TypeOf(Unsupported{}),
+	{"type", "identifier"}:           reflect.TypeOf(IdentifierType{}),
+	{"type", "slice"}:                reflect.TypeOf(SliceType{}),
+	{"type", "array"}:                reflect.TypeOf(ArrayType{}),
+	{"type", "ellipsis"}:             reflect.TypeOf(EllipsisType{}),
+	{"type", "pointer"}:              reflect.TypeOf(PointerType{}),
+	{"type", "interface"}:            reflect.TypeOf(InterfaceType{}),
+	{"type", "union"}:                reflect.TypeOf(UnionType{}),
+	{"type", "term"}:                 reflect.TypeOf(TermType{}),
+	{"type", "map"}:                  reflect.TypeOf(MapType{}),
+	{"type", "chan"}:                 reflect.TypeOf(ChanType{}),
+	{"type", "struct"}:               reflect.TypeOf(StructType{}),
+	{"type", "function"}:             reflect.TypeOf(FuncType{}),
+	{"type", "instantiation"}:        reflect.TypeOf(InstantiationType{}),
+	{"expression", "identifier"}:     reflect.TypeOf(IdentifierExpr{}),
+	{"expression", "index"}:          reflect.TypeOf(IndexExpr{}),
+	{"expression", "instantiation"}:  reflect.TypeOf(InstantiationExpr{}),
+	{"expression", "star"}:           reflect.TypeOf(StarExpr{}),
+	{"expression", "paren"}:          reflect.TypeOf(ParenExpr{}),
+	{"expression", "selector"}:       reflect.TypeOf(SelectorExpr{}),
+	{"expression", "type-assert"}:    reflect.TypeOf(TypeAssertExpr{}),
+	{"expression", "slice"}:          reflect.TypeOf(SliceExpr{}),
+	{"expression", "key-value"}:      reflect.TypeOf(KeyValueExpr{}),
+	{"expression", "call"}:           reflect.TypeOf(CallExpr{}),
+	{"expression", "cast"}:           reflect.TypeOf(CastExpr{}),
+	{"expression", "new"}:            reflect.TypeOf(NewExpr{}),
+	{"expression", "make"}:           reflect.TypeOf(MakeExpr{}),
+	{"field", ""}:                    reflect.TypeOf(Field{}),
+	{"decl", "function"}:             reflect.TypeOf(FuncDecl{}),
+	{"decl", "method"}:               reflect.TypeOf(MethodDecl{}),
+	{"decl", "type-alias"}:           reflect.TypeOf(TypeAliasDecl{}),
+	{"decl", "type"}:                 reflect.TypeOf(GenDecl{}),
+	{"decl", "import"}:               reflect.TypeOf(GenDecl{}),
+	{"decl", "const"}:                reflect.TypeOf(GenDecl{}),
+	{"decl", "var"}:                  reflect.TypeOf(GenDecl{}),
+	{"spec", "type"}:                 reflect.TypeOf(TypeSpec{}),
+	{"spec", "const"}:                reflect.TypeOf(ValueSpec{}),
+	{"spec", "var"}:                  reflect.TypeOf(ValueSpec{}),
+	{"statement", "return"}:          reflect.TypeOf(ReturnStmt{}),
+	{"statement", "assign"}:          reflect.TypeOf(AssignStmt{}),
+	{"statement", "define"}:          reflect.TypeOf(AssignStmt{}),
+	{"statement", "assign-operator"}: reflect.TypeOf(AssignOperatorStmt{}),
+	{"statement", "empty"}:           reflect.TypeOf(EmptyStmt{}),
+	{"statement", "fallthrough"}:     reflect.TypeOf(EmptyStmt{}),
+	{"statement", "expression"}:      reflect.TypeOf(ExprStmt{}),
+	{"statement", "labeled"}:         reflect.TypeOf(LabeledStmt{}),
+	{"statement", "break"}:           reflect.TypeOf(BranchStmt{}),
+	{"statement", "continue"}:        reflect.TypeOf(BranchStmt{}),
+	{"statement", "goto"}:            reflect.TypeOf(BranchStmt{}),
+	{"statement", "range"}:           reflect.TypeOf(RangeStmt{}),
+	{"statement", "declaration"}:     reflect.TypeOf(DeclStmt{}),
+	{"statement", "defer"}:           reflect.TypeOf(DeferStmt{}),
+	{"statement", "go"}:              reflect.TypeOf(DeferStmt{}),
+	{"statement", "if"}:              reflect.TypeOf(IfStmt{}),
+	{"statement", "block"}:           reflect.TypeOf(BlockStmt{}),
+	{"statement", "select"}:          reflect.TypeOf(BlockStmt{}),
+	{"statement", "for"}:             reflect.TypeOf(ForStmt{}),
+	{"statement", "send"}:            reflect.TypeOf(SendStmt{}),
+	{"statement", "crement"}:         reflect.TypeOf(IncDecStmt{}),
+	{"statement", "switch"}:          reflect.TypeOf(SwitchStmt{}),
+	{"statement", "type-switch"}:     reflect.TypeOf(TypeSwitchStmt{}),
+	{"statement", "select-clause"}:   reflect.TypeOf(CommClause{}),
+	{"statement", "case-clause"}:     reflect.TypeOf(CaseClause{}),
+	{"directive", ""}:                reflect.TypeOf(Directive{}),
+	{"constraint", "and"}:            reflect.TypeOf(BinaryConstraint{}),
+	{"constraint", "or"}:             reflect.TypeOf(BinaryConstraint{}),
+	{"constraint", "not"}:            reflect.TypeOf(NotConstraint{}),
+	{"constraint", "tag"}:            reflect.TypeOf(TagConstraint{}),
+	{"file", ""}:                     reflect.TypeOf(File{}),
+	{"package", ""}:                  reflect.TypeOf(Package{}),
+}
+
+// versionedNodeTypes holds the nodes whose "kind" and "type" depend on the
+// format version.
+var versionedNodeTypes = map[FormatVersion]map[[2]string]reflect.Type{
+	FormatV1: {
+		{"unary", ""}:            reflect.TypeOf(UnaryExpr{}),
+		{"binary", "expression"}: reflect.TypeOf(BinaryExpr{}),
+		{"", "import"}:           reflect.TypeOf(ImportSpec{}),
+	},
+	FormatV2: {
+		{"expression", "unary"}:  reflect.TypeOf(UnaryExpr{}),
+		{"expression", "binary"}: reflect.TypeOf(BinaryExpr{}),
+		{"spec", "import"}:       reflect.TypeOf(ImportSpec{}),
+		{"comment-group", ""}:    reflect.TypeOf(CommentGroup{}),
+		{"comment", "line"}:      reflect.TypeOf(Comment{}),
+		{"comment", "block"}:     reflect.TypeOf(Comment{}),
+	},
+}
+
+// Unmarshal decodes goblin JSON into the node types above, choosing each
+// node's type from its "kind" and "type" keys. It accepts every format
+// version.
+func Unmarshal(data []byte) (Node, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var n Node
+	if err := fill(reflect.ValueOf(&n).Elem(), raw); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// nodeType picks the Go type for a dumped node.
+func nodeType(m map[string]interface{}) (reflect.Type, error) {
+	kind, _ := m["kind"].(string)
+	typ, _ := m["type"].(string)
+	for _, key := range [][2]string{{kind, typ}, {kind, "*"}} {
+		if t, ok := nodeTypes[key]; ok {
+			return t, nil
+		}
+		for _, types := range versionedNodeTypes {
+			if t, ok := types[key]; ok {
+				return t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unrecognized node: kind %q, type %q", kind, typ)
+}
+
+// fill stores the decoded JSON value data into v. Nulls leave v untouched,
+// which keeps nil and empty slices apart.
+func fill(v reflect.Value, data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected a node, got %T", data)
+		}
+		t, err := nodeType(m)
+		if err != nil {
+			return err
+		}
+		p := reflect.New(t)
+		if err := fill(p.Elem(), m); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := fill(p.Elem(), data); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Struct:
+		if lines, ok := data.([]interface{}); ok && v.Type() == reflect.TypeOf(CommentGroup{}) {
+			return fill(v.FieldByName("Lines"), lines)
+		}
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected an object for %s, got %T", v.Type().Name(), data)
+		}
+		for i := 0; i < v.NumField(); i++ {
+			key := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+			if key == "-" {
+				continue
+			}
+			if err := fill(v.Field(i), m[key]); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+	case reflect.Slice:
+		a, ok := data.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected an array, got %T", data)
+		}
+		s := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, e := range a {
+			if err := fill(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.String:
+		s, ok := data.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %T", data)
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := data.(bool)
+		if !ok {
+			return fmt.Errorf("expected a bool, got %T", data)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		f, ok := data.(float64)
+		if !ok {
+			return fmt.Errorf("expected a number, got %T", data)
+		}
+		v.SetInt(int64(f))
+	}
+	return nil
+}
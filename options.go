@@ -0,0 +1,174 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// ErrorPolicy decides what a Dumper does when it meets a node it can't dump.
+type ErrorPolicy int
+
+const (
+	// ReturnErrors makes the exported Dumper methods return an *Error.
+	ReturnErrors ErrorPolicy = iota
+	// PanicOnError panics with the error message instead, which is handy
+	// for getting a stack trace out of the executable.
+	PanicOnError
+	// CollectErrors dumps unsupported nodes (including BadExpr, BadStmt and
+	// BadDecl) as "unsupported" placeholders and carries on, returning every
+	// error found as an ErrorList alongside the output.
+	CollectErrors
+)
+
+// PositionMode decides how token.Pos values are turned into positions.
+type PositionMode int
+
+const (
+	// AdjustedPositions honours //line directives.
+	AdjustedPositions PositionMode = iota
+	// RawPositions ignores //line directives and reports where a node
+	// actually is in the file it was parsed from.
+	RawPositions
+	// NoPositions emits null for every position.
+	NoPositions
+)
+
+// CommentMode decides whether comments make it into the output.
+type CommentMode int
+
+const (
+	IncludeComments CommentMode = iota
+	// OmitComments dumps every comment group as an empty list.
+	OmitComments
+)
+
+// OutputShape selects between the current layout of nodes and older ones
+// kept around for consumers that haven't migrated yet.
+type OutputShape int
+
+const (
+	CurrentShape OutputShape = iota
+	// LegacyTypeAliases dumps `type` declarations holding a single spec as
+	// a "type-alias" decl rather than a "type" decl with a list of specs.
+	LegacyTypeAliases
+)
+
+// FormatVersion selects which version of the output format to produce.
+type FormatVersion int
+
+const (
+	// DefaultFormat, the zero value, currently means FormatV1. Newer
+	// versions have to be asked for.
+	DefaultFormat FormatVersion = iota
+	// FormatV1 keeps the kinds and types of the original format, in which
+	// binary expressions are {"kind": "binary", "type": "expression"},
+	// unary expressions have no "type", `true` and `false` aren't wrapped
+	// in an identifier expression like every other identifier, and import
+	// specs have no "kind". It isn't the original output, though: nodes
+	// have gained positions and new keys, type declarations hold a list of
+	// "specs" unless LegacyTypeAliases is asked for, and interfaces list
+	// their embedded types under "embedded" rather than "methods". The
+	// README lists every difference.
+	FormatV1
+	// FormatV2 uses "kind" for the category of every node and "type" for
+	// what it is within that category, so binary and unary expressions are
+	// expressions of type "binary" and "unary", and import specs are specs.
+	FormatV2
+)
+
+// Options configures a Dumper. The zero value is the default configuration.
+// Each Dumper has its own copy, so Dumpers with different options can be
+// used from different goroutines at the same time.
+type Options struct {
+	Errors    ErrorPolicy
+	Positions PositionMode
+	Comments  CommentMode
+	Shape     OutputShape
+	Format    FormatVersion
+	// Ranges adds a "range" holding the start and end positions of every
+	// node that has a "position". It's off by default, as it roughly
+	// doubles the size of the output.
+	Ranges bool
+	// Types, if set, annotates expressions with what go/types found out
+	// about them. It must come from checking the files being dumped.
+	Types *Checked
+}
+
+// format resolves DefaultFormat to the version it currently stands for.
+func (d *Dumper) format() FormatVersion {
+	if d.opts.Format == DefaultFormat {
+		return FormatV1
+	}
+
+	return d.opts.Format
+}
+
+func (d *Dumper) position(p token.Pos) token.Position {
+	pos := d.fset.PositionFor(p, d.opts.Positions != RawPositions)
+	if d.origin.IsValid() {
+		pos = Relative(pos, d.fset.PositionFor(d.origin, d.opts.Positions != RawPositions))
+	}
+
+	return pos
+}
+
+// SetOrigin makes d report positions relative to origin, as if the file it's
+// in started there. This is how snippets parsed by wrapping them in a dummy
+// file, such as those from ParseStmts, get positions relative to the
+// snippet. token.NoPos goes back to ordinary positions.
+func (d *Dumper) SetOrigin(origin token.Pos) {
+	d.origin = origin
+}
+
+// Relative turns pos into a position relative to origin, which becomes line
+// 1, column 1 and offset 0.
+func Relative(pos, origin token.Position) token.Position {
+	if !pos.IsValid() {
+		return pos
+	}
+
+	if pos.Line == origin.Line {
+		pos.Column -= origin.Column - 1
+	}
+	pos.Line -= origin.Line - 1
+	pos.Offset -= origin.Offset
+
+	return pos
+}
+
+func (d *Dumper) dumpPosition(p token.Pos) map[string]interface{} {
+	if d.opts.Positions == NoPositions {
+		return nil
+	}
+
+	return DumpPosition(d.position(p))
+}
+
+func (d *Dumper) dumpRange(n ast.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"start": DumpPosition(d.position(n.Pos())),
+		"end":   DumpPosition(d.position(n.End())),
+	}
+}
+
+// located adds the position of n (and its range, if asked for) to m, along
+// with its types if n is an expression and Options.Types is set.
+func (d *Dumper) located(n ast.Node, m map[string]interface{}) map[string]interface{} {
+	// A node can be dumped more than once, as when we try it as a type
+	// before dumping it as an expression. The last attempt is the one that
+	// makes it into the output.
+	if d.nodes != nil {
+		d.nodes[n] = m
+	}
+
+	if e, ok := n.(ast.Expr); ok && d.opts.Types != nil {
+		d.annotate(e, m)
+	}
+
+	m["position"] = d.dumpPosition(n.Pos())
+	if d.opts.Ranges && d.opts.Positions != NoPositions {
+		m["range"] = d.dumpRange(n)
+	}
+
+	return m
+}
@@ -0,0 +1,131 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// TestFiles decides what ParsePackage does with _test.go files.
+type TestFiles int
+
+const (
+	// ExcludeTests leaves _test.go files out.
+	ExcludeTests TestFiles = iota
+	// IncludeTests adds the _test.go files belonging to the package itself.
+	IncludeTests
+	// ExternalTests parses the package's external tests (package foo_test)
+	// instead of the package itself.
+	ExternalTests
+)
+
+// ParsePackage parses the .go files in dir that the go tool would build with
+// ctxt (build.Default if ctxt is nil), so files excluded by build constraints
+// are skipped. Syntax errors are returned as a scanner.ErrorList alongside
+// whatever could be parsed; any other error means nothing was.
+func ParsePackage(ctxt *build.Context, fset *token.FileSet, dir string, tests TestFiles, mode parser.Mode) (*build.Package, []*ast.File, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+
+	pkg, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	switch tests {
+	case ExcludeTests:
+		names = pkg.GoFiles
+	case IncludeTests:
+		names = append(append(names, pkg.GoFiles...), pkg.TestGoFiles...)
+		sort.Strings(names)
+	case ExternalTests:
+		names = pkg.XTestGoFiles
+		pkg.Name += "_test"
+		if !build.IsLocalImport(pkg.ImportPath) {
+			pkg.ImportPath += "_test"
+		}
+	}
+
+	var files []*ast.File
+	var errs scanner.ErrorList
+	for _, name := range names {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, mode)
+		if list, ok := err.(scanner.ErrorList); ok {
+			errs = append(errs, list...)
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+
+	return pkg, files, errs.Err()
+}
+
+func (d *Dumper) dumpPackage(pkg *build.Package, files []*ast.File) map[string]interface{} {
+	d.packageScope = map[string]bool{}
+	defer func() { d.packageScope = nil }()
+	for _, f := range files {
+		// There's no scope if object resolution was skipped.
+		if f.Scope != nil {
+			for name := range f.Scope.Objects {
+				d.packageScope[name] = true
+			}
+		}
+	}
+
+	dumped := make([]interface{}, len(files))
+	seen := map[string]bool{}
+	imports := []string{}
+	for i, f := range files {
+		dumped[i] = d.dumpFile(f)
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if !seen[path] {
+				seen[path] = true
+				imports = append(imports, path)
+			}
+		}
+	}
+	sort.Strings(imports)
+
+	// A package is spread over several files, so it has no position of its
+	// own. The first file's package clause is the closest thing to one.
+	var position map[string]interface{}
+	if len(files) > 0 {
+		position = d.dumpPosition(files[0].Package)
+	}
+
+	res := map[string]interface{}{
+		"kind":           "package",
+		"format-version": float64(d.format()),
+		"position":       position,
+		"name":           pkg.Name,
+		"files":          dumped,
+		"imports":        imports,
+	}
+
+	// go/build calls packages it can't find an import path for ".".
+	if pkg.ImportPath != "" && !build.IsLocalImport(pkg.ImportPath) {
+		res["import-path"] = pkg.ImportPath
+	}
+
+	return res
+}
+
+// DumpPackage dumps files, which should make up pkg (as returned by
+// ParsePackage), as a single "package" node holding each file's dump, the
+// union of the paths they import and, if known, the package's import path.
+func (d *Dumper) DumpPackage(pkg *build.Package, files []*ast.File) (res map[string]interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+	return d.dumpPackage(pkg, files), d.collected()
+}
@@ -0,0 +1,76 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func fileNames(files []*ast.File, fset *token.FileSet) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(fset.Position(f.Pos()).Filename))
+	}
+	return names
+}
+
+func TestParsePackage(t *testing.T) {
+	cases := []struct {
+		tests TestFiles
+		name  string
+		files []string
+	}{
+		{ExcludeTests, "multifile", []string{"a.go", "b.go"}},
+		{IncludeTests, "multifile", []string{"a.go", "a_test.go", "b.go"}},
+		{ExternalTests, "multifile_test", []string{"b_test.go"}},
+	}
+
+	for _, c := range cases {
+		fset := token.NewFileSet()
+		pkg, files, err := ParsePackage(nil, fset, "testdata/packages/multifile", c.tests, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pkg.Name != c.name || !reflect.DeepEqual(fileNames(files, fset), c.files) {
+			t.Errorf("Parsed package %s from %v, wanted %s from %v", pkg.Name, fileNames(files, fset), c.name, c.files)
+		}
+	}
+
+	if _, _, err := ParsePackage(nil, token.NewFileSet(), "testdata/packages/nonexistent", ExcludeTests, 0); err == nil {
+		t.Error("Didn't complain about a missing directory")
+	}
+}
+
+func TestDumpPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg, files, err := ParsePackage(nil, fset, "testdata/packages/multifile", IncludeTests, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotten, err := NewDumper(fset, Options{}).DumpPackage(pkg, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotten["kind"] != "package" || gotten["name"] != "multifile" || len(gotten["files"].([]interface{})) != 3 {
+		t.Error("Didn't dump every file in the package")
+	}
+
+	if !reflect.DeepEqual(gotten["imports"], []string{"fmt", "strings", "testing"}) {
+		t.Errorf("Wrong union of imports: %v", gotten["imports"])
+	}
+
+	position := gotten["position"].(map[string]interface{})
+	if position["filename"] != filepath.Join("testdata/packages/multifile", "a.go") || position["line"] != float64(1) {
+		t.Errorf("Didn't position the package at its first file's package clause: %v", position)
+	}
+
+	if err := checkSchema(DefaultFormat, gotten); err != nil {
+		t.Error(err)
+	}
+	checkUnmarshal(t, "multifile", gotten)
+}
@@ -0,0 +1,794 @@
+// Package reverse turns the JSON produced by goblin back into go/ast nodes,
+// which can then be printed with go/printer.
+//
+// goblin's output doesn't record everything in the original source: comments,
+// exact positions and redundant parentheses in types are all lost. The
+// reconstructed nodes carry no positions, so go/printer lays them out in its
+// default style.
+package reverse
+
+import (
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"io"
+	"strconv"
+
+	"github.com/ReconfigureIO/goblin"
+)
+
+// go/printer only prints some tokens (the `=` of an alias, the `...` of a
+// variadic call) if their position is valid, so we give them this one.
+const present = token.Pos(1)
+
+type node = map[string]interface{}
+
+// File rebuilds a file from the output of Dumper.DumpFile.
+func File(n map[string]interface{}) (f *ast.File, err error) {
+	defer catch(&err)
+	return file(n), nil
+}
+
+// Decl rebuilds a declaration from the output of Dumper.DumpDecl.
+func Decl(n map[string]interface{}) (d ast.Decl, err error) {
+	defer catch(&err)
+	return decl(n), nil
+}
+
+// Stmt rebuilds a statement from the output of Dumper.DumpStmt.
+func Stmt(n map[string]interface{}) (s ast.Stmt, err error) {
+	defer catch(&err)
+	return stmt(n), nil
+}
+
+// Expr rebuilds an expression (or a type) from the output of Dumper.DumpExpr.
+func Expr(n map[string]interface{}) (e ast.Expr, err error) {
+	defer catch(&err)
+	return expr(n), nil
+}
+
+// Stmts rebuilds a list of statements from the output of Dumper.DumpStmts.
+func Stmts(list []interface{}) (s []ast.Stmt, err error) {
+	defer catch(&err)
+	return stmts(nodes(list)), nil
+}
+
+// Decls rebuilds a list of declarations from the output of Dumper.DumpDecls.
+func Decls(list []interface{}) (d []ast.Decl, err error) {
+	defer catch(&err)
+	for _, v := range nodes(list) {
+		d = append(d, decl(v))
+	}
+
+	return d, nil
+}
+
+// Node rebuilds whatever n happens to be, judging by its kind. A package
+// can't be rebuilt as a single node, so its files have to be rebuilt one at
+// a time.
+func Node(n map[string]interface{}) (ast.Node, error) {
+	switch n["kind"] {
+	case "file":
+		return File(n)
+	case "decl":
+		return Decl(n)
+	case "statement":
+		return Stmt(n)
+	case "package":
+		return nil, &goblin.Error{Type: "unexpected_node", Info: "a package has to be rebuilt one file at a time", Position: position(n)}
+	}
+
+	return Expr(n)
+}
+
+// Fprint prints a rebuilt node, or a list of them, as gofmt would lay it out.
+// Unlike go/format, it leaves imports in the order they were dumped in.
+func Fprint(w io.Writer, node interface{}) error {
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	return config.Fprint(w, token.NewFileSet(), node)
+}
+
+func catch(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	e, ok := r.(*goblin.Error)
+	if !ok {
+		// Anything else is a bug, but it shouldn't take the caller down.
+		e = &goblin.Error{Type: "internal_error", Info: fmt.Sprint(r), Position: goblin.INVALID_POSITION}
+	}
+
+	*err = e
+}
+
+func position(n node) token.Position {
+	p, ok := n["position"].(node)
+	if !ok {
+		return goblin.INVALID_POSITION
+	}
+
+	number := func(key string) int {
+		f, _ := p[key].(float64)
+		return int(f)
+	}
+
+	filename, _ := p["filename"].(string)
+	return token.Position{Filename: filename, Offset: number("offset"), Line: number("line"), Column: number("column")}
+}
+
+func perish(n node, typ string, reason string) {
+	panic(&goblin.Error{Type: typ, Info: reason, Position: position(n)})
+}
+
+func unexpected(n node) {
+	kind, _ := n["kind"].(string)
+	typ, _ := n["type"].(string)
+	perish(n, "unexpected_node", kind+"/"+typ)
+}
+
+// child fetches a nested node, which is nil if it was null in the JSON.
+func child(n node, key string) node {
+	c, _ := n[key].(node)
+	return c
+}
+
+func children(n node, key string) []node {
+	list, _ := n[key].([]interface{})
+	return nodes(list)
+}
+
+func nodes(list []interface{}) []node {
+	if list == nil {
+		return nil
+	}
+
+	result := make([]node, len(list))
+	for i, v := range list {
+		result[i], _ = v.(node)
+	}
+
+	return result
+}
+
+func str(n node, key string) string {
+	s, _ := n[key].(string)
+	return s
+}
+
+func flag(n node, key string) bool {
+	b, _ := n[key].(bool)
+	return b
+}
+
+func file(n node) *ast.File {
+	if n["kind"] != "file" {
+		unexpected(n)
+	}
+
+	var decls []ast.Decl
+	for _, v := range children(n, "declarations") {
+		decls = append(decls, decl(v))
+	}
+
+	return &ast.File{
+		Name:  ident(child(n, "name")),
+		Decls: decls,
+	}
+}
+
+func ident(n node) *ast.Ident {
+	if n == nil {
+		return nil
+	}
+
+	switch n["kind"] {
+	case "ident":
+		return ast.NewIdent(str(n, "value"))
+
+	case "literal":
+		switch n["type"] {
+		case "BOOL":
+			return ast.NewIdent(str(n, "value"))
+		case "IOTA":
+			return ast.NewIdent("iota")
+		}
+	}
+
+	unexpected(n)
+	panic("unreachable")
+}
+
+func idents(list []node) []*ast.Ident {
+	var result []*ast.Ident
+	for _, v := range list {
+		result = append(result, ident(v))
+	}
+
+	return result
+}
+
+func qualified(n node) ast.Expr {
+	name := ident(child(n, "value"))
+	if q := child(n, "qualifier"); q != nil {
+		return &ast.SelectorExpr{X: ident(q), Sel: name}
+	}
+
+	return name
+}
+
+func exprs(list []node) []ast.Expr {
+	var result []ast.Expr
+	for _, v := range list {
+		result = append(result, expr(v))
+	}
+
+	return result
+}
+
+func field(n node) *ast.Field {
+	var tag *ast.BasicLit
+	if t := child(n, "tag"); t != nil {
+		lit, ok := expr(t).(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			unexpected(t)
+		}
+		tag = lit
+	}
+
+	var typ ast.Expr
+	if t := child(n, "declared-type"); t != nil {
+		typ = expr(t)
+	}
+
+	return &ast.Field{
+		Names: idents(children(n, "names")),
+		Type:  typ,
+		Tag:   tag,
+	}
+}
+
+// fields returns nil for a null list, which go/printer treats as absent.
+func fields(n node, key string) *ast.FieldList {
+	if n[key] == nil {
+		return nil
+	}
+
+	list := &ast.FieldList{}
+	for _, v := range children(n, key) {
+		list.List = append(list.List, field(v))
+	}
+
+	return list
+}
+
+// requiredFields is like fields, but for lists that go/printer insists on.
+func requiredFields(n node, key string) *ast.FieldList {
+	if list := fields(n, key); list != nil {
+		return list
+	}
+
+	return &ast.FieldList{}
+}
+
+func funcType(n node) *ast.FuncType {
+	return &ast.FuncType{
+		TypeParams: fields(n, "type-params"),
+		Params:     requiredFields(n, "params"),
+		Results:    fields(n, "results"),
+	}
+}
+
+// typ rebuilds the nodes of kind "type".
+func typ(n node) ast.Expr {
+	switch n["type"] {
+	case "identifier":
+		return qualified(n)
+
+	case "slice":
+		return &ast.ArrayType{Elt: expr(child(n, "element"))}
+
+	case "array":
+		return &ast.ArrayType{Len: expr(child(n, "length")), Elt: expr(child(n, "element"))}
+
+	case "ellipsis":
+		var elt ast.Expr
+		if v := child(n, "value"); v != nil {
+			elt = expr(v)
+		}
+		return &ast.Ellipsis{Elt: elt}
+
+	case "pointer":
+		return &ast.StarExpr{X: expr(child(n, "contained"))}
+
+	case "map":
+		return &ast.MapType{Key: expr(child(n, "key")), Value: expr(child(n, "value"))}
+
+	case "chan":
+		dir := ast.SEND | ast.RECV
+		switch n["direction"] {
+		case "send":
+			dir = ast.SEND
+		case "recv":
+			dir = ast.RECV
+		}
+		return &ast.ChanType{Dir: dir, Value: expr(child(n, "value"))}
+
+	case "struct":
+		return &ast.StructType{Fields: requiredFields(n, "fields")}
+
+	case "function":
+		return funcType(n)
+
+	case "interface":
+		list := &ast.FieldList{}
+		for _, v := range children(n, "methods") {
+			list.List = append(list.List, field(v))
+		}
+		for _, v := range children(n, "embedded") {
+			list.List = append(list.List, &ast.Field{Type: expr(v)})
+		}
+		for _, v := range children(n, "type-set") {
+			list.List = append(list.List, &ast.Field{Type: expr(v)})
+		}
+		return &ast.InterfaceType{Methods: list}
+
+	case "union":
+		var result ast.Expr
+		for _, v := range children(n, "terms") {
+			term := expr(child(v, "value"))
+			if flag(v, "tilde") {
+				term = &ast.UnaryExpr{Op: token.TILDE, X: term}
+			}
+
+			if result == nil {
+				result = term
+			} else {
+				result = &ast.BinaryExpr{X: result, Op: token.OR, Y: term}
+			}
+		}
+		return result
+
+	case "instantiation":
+		return instantiation(expr(child(n, "generic")), exprs(children(n, "arguments")))
+	}
+
+	unexpected(n)
+	panic("unreachable")
+}
+
+func instantiation(generic ast.Expr, args []ast.Expr) ast.Expr {
+	if len(args) == 1 {
+		return &ast.IndexExpr{X: generic, Index: args[0]}
+	}
+
+	return &ast.IndexListExpr{X: generic, Indices: args}
+}
+
+func expr(n node) ast.Expr {
+	if n == nil {
+		return nil
+	}
+
+	switch n["kind"] {
+	case "type":
+		return typ(n)
+
+	case "literal":
+		return literal(n)
+
+	// Format version 1 gave unary and binary expressions kinds of their own.
+	case "unary":
+		return &ast.UnaryExpr{Op: operator(n, str(n, "operator")), X: expr(child(n, "target"))}
+
+	case "binary":
+		return &ast.BinaryExpr{X: expr(child(n, "left")), Op: operator(n, str(n, "operator")), Y: expr(child(n, "right"))}
+
+	case "expression":
+		return expression(n)
+
+	case "unsupported":
+		perish(n, "unsupported_node", str(n, "type"))
+	}
+
+	unexpected(n)
+	panic("unreachable")
+}
+
+var operators = map[string]token.Token{}
+
+func init() {
+	for t := token.ADD; t <= token.TILDE; t++ {
+		if t.IsOperator() {
+			operators[t.String()] = t
+		}
+	}
+}
+
+func operator(n node, op string) token.Token {
+	t, ok := operators[op]
+	if !ok {
+		perish(n, "unrecognized_token", op)
+	}
+
+	return t
+}
+
+var literals = map[string]token.Token{
+	"INT":    token.INT,
+	"FLOAT":  token.FLOAT,
+	"IMAG":   token.IMAG,
+	"CHAR":   token.CHAR,
+	"STRING": token.STRING,
+}
+
+func literal(n node) ast.Expr {
+	switch n["type"] {
+	case "BOOL", "IOTA":
+		return ident(n)
+
+	case "function":
+		return &ast.FuncLit{Type: funcType(n), Body: block(n, "body")}
+
+	case "composite":
+		var declared ast.Expr
+		if t := child(n, "declared"); t != nil {
+			declared = expr(t)
+		}
+		return &ast.CompositeLit{Type: declared, Elts: exprs(children(n, "values"))}
+	}
+
+	kind, ok := literals[str(n, "type")]
+	if !ok {
+		unexpected(n)
+	}
+
+	return &ast.BasicLit{Kind: kind, Value: str(n, "value")}
+}
+
+// In an expression, some types have to be parenthesized to be parsed back as
+// the same thing: `(*T)(x)` is a conversion, `*T(x)` is a dereference.
+func parenthesized(e ast.Expr) ast.Expr {
+	switch e.(type) {
+	case *ast.StarExpr, *ast.ChanType, *ast.FuncType:
+		return &ast.ParenExpr{X: e}
+	}
+
+	return e
+}
+
+func expression(n node) ast.Expr {
+	switch n["type"] {
+	case "identifier":
+		return qualified(n)
+
+	case "unary":
+		return &ast.UnaryExpr{Op: operator(n, str(n, "operator")), X: expr(child(n, "target"))}
+
+	case "binary":
+		return &ast.BinaryExpr{X: expr(child(n, "left")), Op: operator(n, str(n, "operator")), Y: expr(child(n, "right"))}
+
+	case "index":
+		return &ast.IndexExpr{X: expr(child(n, "target")), Index: expr(child(n, "index"))}
+
+	case "instantiation":
+		return instantiation(expr(child(n, "generic")), exprs(children(n, "arguments")))
+
+	case "star":
+		return &ast.StarExpr{X: expr(child(n, "target"))}
+
+	case "paren":
+		return &ast.ParenExpr{X: expr(child(n, "target"))}
+
+	case "selector":
+		return &ast.SelectorExpr{X: expr(child(n, "target")), Sel: ident(child(n, "field"))}
+
+	case "type-assert":
+		return &ast.TypeAssertExpr{X: expr(child(n, "target")), Type: expr(child(n, "asserted"))}
+
+	case "slice":
+		return &ast.SliceExpr{
+			X:      expr(child(n, "target")),
+			Low:    expr(child(n, "low")),
+			High:   expr(child(n, "high")),
+			Max:    expr(child(n, "max")),
+			Slice3: flag(n, "three"),
+		}
+
+	case "key-value":
+		return &ast.KeyValueExpr{Key: expr(child(n, "key")), Value: expr(child(n, "value"))}
+
+	case "call":
+		call := &ast.CallExpr{Fun: expr(child(n, "function")), Args: exprs(children(n, "arguments"))}
+		if flag(n, "ellipsis") {
+			call.Ellipsis = present
+		}
+		return call
+
+	case "cast":
+		return &ast.CallExpr{
+			Fun:  parenthesized(expr(child(n, "coerced-to"))),
+			Args: []ast.Expr{expr(child(n, "target"))},
+		}
+
+	case "new":
+		return &ast.CallExpr{Fun: ast.NewIdent("new"), Args: []ast.Expr{expr(child(n, "argument"))}}
+
+	case "make":
+		args := append([]ast.Expr{expr(child(n, "argument"))}, exprs(children(n, "rest"))...)
+		return &ast.CallExpr{Fun: ast.NewIdent("make"), Args: args}
+	}
+
+	unexpected(n)
+	panic("unreachable")
+}
+
+func call(n node) *ast.CallExpr {
+	c, ok := expr(n).(*ast.CallExpr)
+	if !ok {
+		unexpected(n)
+	}
+
+	return c
+}
+
+func stmts(list []node) []ast.Stmt {
+	var result []ast.Stmt
+	for _, v := range list {
+		result = append(result, stmt(v))
+	}
+
+	return result
+}
+
+// block returns nil for a null body, as in a function declared without one.
+func block(n node, key string) *ast.BlockStmt {
+	if n[key] == nil {
+		return nil
+	}
+
+	return &ast.BlockStmt{List: stmts(children(n, key))}
+}
+
+func optionalStmt(n node, key string) ast.Stmt {
+	if c := child(n, key); c != nil {
+		return stmt(c)
+	}
+
+	return nil
+}
+
+func stmt(n node) ast.Stmt {
+	if n == nil {
+		return nil
+	}
+
+	if n["kind"] == "unsupported" {
+		perish(n, "unsupported_node", str(n, "type"))
+	}
+
+	if n["kind"] != "statement" {
+		unexpected(n)
+	}
+
+	switch n["type"] {
+	case "return":
+		return &ast.ReturnStmt{Results: exprs(children(n, "values"))}
+
+	case "assign", "define", "assign-operator":
+		tok := token.ASSIGN
+		if n["type"] == "define" {
+			tok = token.DEFINE
+		} else if n["type"] == "assign-operator" {
+			tok = operator(n, str(n, "operator")+"=")
+		}
+		return &ast.AssignStmt{Lhs: exprs(children(n, "left")), Tok: tok, Rhs: exprs(children(n, "right"))}
+
+	case "empty":
+		return &ast.EmptyStmt{Implicit: true}
+
+	case "expression":
+		return &ast.ExprStmt{X: expr(child(n, "value"))}
+
+	case "labeled":
+		return &ast.LabeledStmt{Label: ident(child(n, "label")), Stmt: stmt(child(n, "statement"))}
+
+	case "break":
+		return &ast.BranchStmt{Tok: token.BREAK, Label: ident(child(n, "label"))}
+
+	case "continue":
+		return &ast.BranchStmt{Tok: token.CONTINUE, Label: ident(child(n, "label"))}
+
+	case "goto":
+		return &ast.BranchStmt{Tok: token.GOTO, Label: ident(child(n, "label"))}
+
+	case "fallthrough":
+		return &ast.BranchStmt{Tok: token.FALLTHROUGH}
+
+	case "range":
+		r := &ast.RangeStmt{
+			Key:   expr(child(n, "key")),
+			Value: expr(child(n, "value")),
+			X:     expr(child(n, "target")),
+			Body:  block(n, "body"),
+		}
+		if r.Key != nil {
+			r.Tok = token.ASSIGN
+			if flag(n, "is-assign") {
+				r.Tok = token.DEFINE
+			}
+		}
+		return r
+
+	case "declaration":
+		return &ast.DeclStmt{Decl: decl(child(n, "target"))}
+
+	case "defer":
+		return &ast.DeferStmt{Call: call(child(n, "target"))}
+
+	case "go":
+		return &ast.GoStmt{Call: call(child(n, "target"))}
+
+	case "if":
+		return &ast.IfStmt{
+			Init: optionalStmt(n, "init"),
+			Cond: expr(child(n, "condition")),
+			Body: block(n, "body"),
+			Else: optionalStmt(n, "else"),
+		}
+
+	case "block":
+		return block(n, "body")
+
+	case "for":
+		return &ast.ForStmt{
+			Init: optionalStmt(n, "init"),
+			Cond: expr(child(n, "condition")),
+			Post: optionalStmt(n, "post"),
+			Body: block(n, "body"),
+		}
+
+	case "send":
+		return &ast.SendStmt{Chan: expr(child(n, "channel")), Value: expr(child(n, "value"))}
+
+	case "select":
+		return &ast.SelectStmt{Body: block(n, "body")}
+
+	case "crement":
+		tok := token.INC
+		if n["operation"] == "--" {
+			tok = token.DEC
+		}
+		return &ast.IncDecStmt{X: expr(child(n, "target")), Tok: tok}
+
+	case "switch":
+		return &ast.SwitchStmt{
+			Init: optionalStmt(n, "init"),
+			Tag:  expr(child(n, "condition")),
+			Body: block(n, "body"),
+		}
+
+	case "type-switch":
+		return &ast.TypeSwitchStmt{
+			Init:   optionalStmt(n, "init"),
+			Assign: stmt(child(n, "assign")),
+			Body:   block(n, "body"),
+		}
+
+	case "select-clause":
+		return &ast.CommClause{Comm: optionalStmt(n, "statement"), Body: stmts(children(n, "body"))}
+
+	case "case-clause":
+		return &ast.CaseClause{List: exprs(children(n, "expressions")), Body: stmts(children(n, "body"))}
+	}
+
+	unexpected(n)
+	panic("unreachable")
+}
+
+func typeSpec(n node) *ast.TypeSpec {
+	spec := &ast.TypeSpec{
+		Name:       ident(child(n, "name")),
+		TypeParams: fields(n, "type-params"),
+		Type:       expr(child(n, "value")),
+	}
+	if flag(n, "alias") {
+		spec.Assign = present
+	}
+
+	return spec
+}
+
+func valueSpec(n node) *ast.ValueSpec {
+	var declared ast.Expr
+	if t := child(n, "declared-type"); t != nil {
+		declared = expr(t)
+	}
+
+	return &ast.ValueSpec{
+		Names:  idents(children(n, "names")),
+		Type:   declared,
+		Values: exprs(children(n, "values")),
+	}
+}
+
+func importSpec(n node) *ast.ImportSpec {
+	return &ast.ImportSpec{
+		Name: ident(child(n, "name")),
+		Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(str(n, "path"))},
+	}
+}
+
+// genDecl parenthesizes every declaration holding more than one spec; we
+// can't tell whether a single spec was parenthesized to begin with.
+func genDecl(n node, tok token.Token) *ast.GenDecl {
+	d := &ast.GenDecl{Tok: tok}
+	for _, v := range children(n, "specs") {
+		switch tok {
+		case token.IMPORT:
+			d.Specs = append(d.Specs, importSpec(v))
+		case token.TYPE:
+			d.Specs = append(d.Specs, typeSpec(v))
+		default:
+			d.Specs = append(d.Specs, valueSpec(v))
+		}
+	}
+
+	if len(d.Specs) > 1 {
+		d.Lparen = present
+		d.Rparen = present
+	}
+
+	return d
+}
+
+func receiver(n node) *ast.FieldList {
+	return &ast.FieldList{List: []*ast.Field{field(child(n, "receiver"))}}
+}
+
+func decl(n node) ast.Decl {
+	if n["kind"] == "unsupported" {
+		perish(n, "unsupported_node", str(n, "type"))
+	}
+
+	if n["kind"] != "decl" {
+		unexpected(n)
+	}
+
+	switch n["type"] {
+	case "function":
+		return &ast.FuncDecl{
+			Name: ident(child(n, "name")),
+			Type: funcType(n),
+			Body: block(n, "body"),
+		}
+
+	case "method":
+		return &ast.FuncDecl{
+			Recv: receiver(n),
+			Name: ident(child(n, "name")),
+			Type: &ast.FuncType{Params: requiredFields(n, "params"), Results: fields(n, "results")},
+			Body: block(n, "body"),
+		}
+
+	case "type-alias":
+		return &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{typeSpec(n)}}
+
+	case "type":
+		return genDecl(n, token.TYPE)
+
+	case "import":
+		return genDecl(n, token.IMPORT)
+
+	case "const":
+		return genDecl(n, token.CONST)
+
+	case "var":
+		return genDecl(n, token.VAR)
+	}
+
+	unexpected(n)
+	panic("unreachable")
+}
@@ -0,0 +1,157 @@
+package reverse
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ReconfigureIO/goblin"
+)
+
+// dump dumps src as JSON, with every position (which can't survive the round
+// trip) left out.
+func dump(t *testing.T, name string, src []byte, opts goblin.Options) map[string]interface{} {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, 0)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	opts.Positions = goblin.NoPositions
+	dumped, err := goblin.NewDumper(fset, opts).DumpFile(f)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	var n map[string]interface{}
+	text, _ := json.Marshal(dumped)
+	json.Unmarshal(text, &n)
+	return n
+}
+
+func TestRoundTrip(t *testing.T) {
+	var paths []string
+	for _, pattern := range []string{"../testdata/packages/*/*.go", "../*.go", "*.go", "../cmd/goblin/*.go"} {
+		matches, _ := filepath.Glob(pattern)
+		paths = append(paths, matches...)
+	}
+
+	for _, p := range paths {
+		src, _ := ioutil.ReadFile(p)
+		for _, format := range []goblin.FormatVersion{goblin.FormatV1, goblin.FormatV2} {
+			opts := goblin.Options{Format: format}
+			original := dump(t, p, src, opts)
+
+			rebuilt, err := File(original)
+			if err != nil {
+				t.Fatalf("%s: %s", p, err)
+			}
+
+			var buf bytes.Buffer
+			Fprint(&buf, rebuilt)
+			if again := dump(t, p, buf.Bytes(), opts); !reflect.DeepEqual(original, again) {
+				t.Errorf("%s in format v%d doesn't dump the same once rebuilt:\n%s", p, format, buf.Bytes())
+			}
+		}
+	}
+}
+
+// dumpExpr dumps src, an expression, as dump dumps a file.
+func dumpExpr(t *testing.T, name string, src []byte) map[string]interface{} {
+	fset := token.NewFileSet()
+	e, err := parser.ParseExprFrom(fset, name, src, 0)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	dumped, err := goblin.NewDumper(fset, goblin.Options{Positions: goblin.NoPositions}).DumpExpr(e)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	var n map[string]interface{}
+	text, _ := json.Marshal(dumped)
+	json.Unmarshal(text, &n)
+	return n
+}
+
+func TestExpressionFixtures(t *testing.T) {
+	paths, _ := filepath.Glob("../testdata/expressions/*/*.go.txt")
+	for _, p := range paths {
+		src, _ := ioutil.ReadFile(p)
+		original := dumpExpr(t, p, src)
+
+		rebuilt, err := Expr(original)
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+
+		var buf bytes.Buffer
+		printer.Fprint(&buf, token.NewFileSet(), rebuilt)
+		if again := dumpExpr(t, p, buf.Bytes()); !reflect.DeepEqual(original, again) {
+			t.Errorf("%s: %s doesn't dump the same as the original", p, buf.String())
+		}
+	}
+}
+
+// jsonList dumps list to JSON and back, as --reverse would read it.
+func jsonList(list []interface{}) []interface{} {
+	var result []interface{}
+	text, _ := json.Marshal(list)
+	json.Unmarshal(text, &result)
+	return result
+}
+
+func TestStmtsAndDecls(t *testing.T) {
+	fset := token.NewFileSet()
+	d := goblin.NewDumper(fset, goblin.Options{})
+
+	parsed, _, _ := goblin.ParseStmts(fset, "x := 1\ny := x")
+	dumped, _ := d.DumpStmts(parsed)
+	stmts, err := Stmts(jsonList(dumped))
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), stmts)
+	if err != nil || buf.String() != "x := 1\ny := x" {
+		t.Errorf("Didn't rebuild a list of statements: %q, %v", buf.String(), err)
+	}
+
+	decls, _, _ := goblin.ParseDecls(fset, "var x = 1\n\nfunc f() {}")
+	dumped, _ = d.DumpDecls(decls)
+	rebuilt, err := Decls(jsonList(dumped))
+	if err != nil || len(rebuilt) != 2 {
+		t.Errorf("Didn't rebuild a list of declarations: %v", err)
+	}
+
+	pkg := map[string]interface{}{"kind": "package", "files": []interface{}{}}
+	if _, err := Node(pkg); err == nil {
+		t.Error("Didn't refuse to rebuild a package as a single node")
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	var n map[string]interface{}
+	json.Unmarshal([]byte(`{"kind": "type", "type": "struct", "fields": [{
+		"kind": "field",
+		"names": [{"kind": "ident", "value": "A"}],
+		"declared-type": {"kind": "type", "type": "identifier", "value": {"kind": "ident", "value": "int"}},
+		"tag": {"kind": "expression", "type": "identifier", "value": {"kind": "ident", "value": "x"}}
+	}]}`), &n)
+
+	_, err := Expr(n)
+	if e, ok := err.(*goblin.Error); !ok || e.Type != "unexpected_node" {
+		t.Errorf("Didn't refuse a tag that isn't a string literal: %v", err)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	_, err := Expr(map[string]interface{}{"kind": "unsupported", "type": "*ast.BadExpr"})
+	if e, ok := err.(*goblin.Error); !ok || e.Type != "unsupported_node" {
+		t.Error("Didn't refuse to rebuild an unsupported node")
+	}
+}
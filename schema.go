@@ -0,0 +1,176 @@
+package goblin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema returns a JSON Schema describing everything a Dumper can produce in
+// the given format version. It is built from the node types in nodes.go, so
+// the two can't disagree: there is one definition per node type, and "Node"
+// accepts any of them. Fields tagged `schema:"nullable"` may be null, as may
+// every "position" (positions can be turned off). Fields tagged `schema:"v1"`
+// or `schema:"v2"` are left out of the other version's schema.
+func Schema(version FormatVersion) map[string]interface{} {
+	if version == DefaultFormat {
+		version = FormatV1
+	}
+
+	definitions := map[string]interface{}{
+		"Position":       schemaObject(version, reflect.TypeOf(Position{}), nil),
+		"Range":          schemaObject(version, reflect.TypeOf(Range{}), nil),
+		"TypeAnnotation": schemaObject(version, reflect.TypeOf(TypeAnnotation{}), nil),
+	}
+
+	// Gather the "kind" and "type" values each node type is used for.
+	values := map[reflect.Type]map[string][]string{}
+	for _, types := range []map[[2]string]reflect.Type{nodeTypes, versionedNodeTypes[version]} {
+		for key, t := range types {
+			if values[t] == nil {
+				values[t] = map[string][]string{}
+			}
+			values[t]["kind"] = append(values[t]["kind"], key[0])
+			values[t]["type"] = append(values[t]["type"], key[1])
+		}
+	}
+
+	var nodes []interface{}
+	for t, v := range values {
+		definitions[t.Name()] = schemaObject(version, t, v)
+		nodes = append(nodes, schemaRef(t.Name()))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].(map[string]interface{})["$ref"].(string) < nodes[j].(map[string]interface{})["$ref"].(string)
+	})
+	definitions["Node"] = map[string]interface{}{"oneOf": nodes}
+
+	if version == FormatV1 {
+		definitions["CommentGroup"] = map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		}
+	}
+
+	for _, name := range []string{"File", "Package"} {
+		properties := definitions[name].(map[string]interface{})["properties"].(map[string]interface{})
+		properties["format-version"] = map[string]interface{}{"const": int(version)}
+	}
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "goblin",
+		"description": "A Go syntax tree as dumped by goblin.",
+		// Draft 7 ignores anything next to a "$ref", so wrap it.
+		"allOf":       []interface{}{schemaRef("Node")},
+		"definitions": definitions,
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/definitions/" + name}
+}
+
+func schemaNullable(s map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}},
+	}
+}
+
+// schemaObject describes the struct t in the given format version. For node
+// types, values holds the strings their "kind" and "type" fields are limited
+// to, using the same conventions as nodeTypes.
+func schemaObject(version FormatVersion, t reflect.Type, values map[string][]string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+
+fields:
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		s := schemaType(f.Type)
+
+		nullable := false
+		for _, option := range strings.Split(f.Tag.Get("schema"), ",") {
+			switch option {
+			case "nullable":
+				nullable = true
+			case "v1", "v2":
+				if option != fmt.Sprintf("v%d", version) {
+					continue fields
+				}
+				if tag[len(tag)-1] == "omitzero" {
+					// It's only omitted in the other version.
+					tag = tag[:1]
+				}
+			}
+		}
+
+		if v, ok := values[name]; ok {
+			var enum []interface{}
+			seen := map[string]bool{}
+			for _, e := range v {
+				if e == "*" {
+					enum = nil
+					break
+				}
+				if e != "" && !seen[e] {
+					enum = append(enum, e)
+					seen[e] = true
+				}
+			}
+			sort.Slice(enum, func(i, j int) bool { return enum[i].(string) < enum[j].(string) })
+
+			switch {
+			case len(enum) == 0 && v[0] == "":
+				// Nodes of this type don't have this field (in this
+				// format version).
+				continue
+			case len(enum) == 1:
+				s = map[string]interface{}{"const": enum[0]}
+			case len(enum) > 1:
+				s = map[string]interface{}{"enum": enum}
+			}
+			tag = tag[:1]
+		}
+
+		if nullable || name == "position" {
+			s = schemaNullable(s)
+		}
+		properties[name] = s
+
+		if len(tag) == 1 {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func schemaType(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaType(t.Elem())}
+	case reflect.Ptr:
+		return schemaRef(t.Elem().Name())
+	case reflect.Interface:
+		return schemaRef("Node")
+	}
+	panic("no schema for " + t.String())
+}
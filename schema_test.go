@@ -0,0 +1,381 @@
+package goblin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// discriminatorError means a node's "kind" or "type" ruled out a definition.
+type discriminatorError struct {
+	error
+}
+
+// validate checks value against the parts of JSON Schema (draft 7) that Schema
+// and the draft 7 meta-schema use. Annotations such as "format" and "default"
+// are ignored, and every keyword of a schema has to hold.
+func validate(root map[string]interface{}, schema interface{}, value interface{}) error {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return fmt.Errorf("nothing is allowed here, got %v", value)
+		}
+		return nil
+
+	case map[string]interface{}:
+		schema := s
+
+		if ref, ok := schema["$ref"].(string); ok {
+			if ref == "#" {
+				return validate(root, root, value)
+			}
+			name := ref[len("#/definitions/"):]
+			return validate(root, root["definitions"].(map[string]interface{})[name], value)
+		}
+
+		for _, check := range []func(map[string]interface{}, map[string]interface{}, interface{}) error{
+			validateType,
+			validateAlternatives,
+			validateValue,
+			validateArray,
+			validateObject,
+		} {
+			if err := check(root, schema, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	panic(fmt.Sprintf("a schema has to be an object or a boolean, not %v", schema))
+}
+
+func validateType(root, schema map[string]interface{}, value interface{}) error {
+	var types []interface{}
+	switch t := schema["type"].(type) {
+	case nil:
+		return nil
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	}
+
+	for _, t := range types {
+		if hasType(t.(string), value) {
+			return nil
+		}
+	}
+	if len(types) == 1 {
+		return fmt.Errorf("expected %s %s, got %v", article(types[0].(string)), types[0], value)
+	}
+	return fmt.Errorf("expected one of %v, got %v", types, value)
+}
+
+func hasType(t string, value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && value == float64(int64(value))
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+func article(t string) string {
+	if strings.IndexByte("aeiou", t[0]) >= 0 {
+		return "an"
+	}
+	return "a"
+}
+
+func validateAlternatives(root, schema map[string]interface{}, value interface{}) error {
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range all {
+			if err := validate(root, s, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if alternatives, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		var err error
+		for _, s := range alternatives {
+			e := validate(root, s, value)
+			if e == nil {
+				matched = true
+				break
+			}
+			// Schema only uses anyOf for nullable fields, so report
+			// why the value wasn't what it should have been rather
+			// than why it wasn't null.
+			if err == nil {
+				err = e
+			}
+		}
+		if !matched {
+			return err
+		}
+	}
+
+	if alternatives, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		var err error
+		for _, s := range alternatives {
+			e := validate(root, s, value)
+			if e == nil {
+				matches++
+			} else if _, ok := e.(discriminatorError); !ok || err == nil {
+				err = e
+			}
+		}
+		if matches == 0 {
+			return err
+		}
+		if matches > 1 {
+			return fmt.Errorf("%d alternatives match", matches)
+		}
+	}
+
+	return nil
+}
+
+func validateValue(root, schema map[string]interface{}, value interface{}) error {
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(value, c) {
+		return fmt.Errorf("expected %v, got %v", c, value)
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%v isn't one of %v", value, enum)
+		}
+	}
+
+	if f, ok := value.(float64); ok {
+		if min, ok := schema["minimum"].(float64); ok && f < min {
+			return fmt.Errorf("%v is less than %v", f, min)
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && f <= min {
+			return fmt.Errorf("%v isn't more than %v", f, min)
+		}
+	}
+
+	return nil
+}
+
+func validateArray(root, schema map[string]interface{}, value interface{}) error {
+	a, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	if min, ok := schema["minItems"].(float64); ok && float64(len(a)) < min {
+		return fmt.Errorf("expected at least %v items, got %d", min, len(a))
+	}
+
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range a {
+			for j := i + 1; j < len(a); j++ {
+				if reflect.DeepEqual(a[i], a[j]) {
+					return fmt.Errorf("[%d] and [%d] are the same", i, j)
+				}
+			}
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		for i, e := range a {
+			if err := validate(root, items, e); err != nil {
+				return fmt.Errorf("[%d]: %v", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateObject(root, schema map[string]interface{}, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+	closed := additional == false
+
+	list, _ := schema["required"].([]interface{})
+	required := map[string]bool{}
+	for _, r := range list {
+		required[r.(string)] = true
+	}
+
+	// Check "kind" and "type" first, so a mismatched alternative is thrown
+	// out before we descend into it. They're only discriminators where
+	// they're required, or where the schema rules them out: a type
+	// annotation's "type" is just a field.
+	for _, k := range []string{"kind", "type"} {
+		s, known := properties[k]
+		v, present := m[k]
+		switch {
+		case required[k] && !present:
+			return discriminatorError{fmt.Errorf("missing %q", k)}
+		case present && !known && closed:
+			return discriminatorError{fmt.Errorf("%q shouldn't be present", k)}
+		case required[k]:
+			if err := validate(root, s, v); err != nil {
+				return discriminatorError{fmt.Errorf("%s: %v", k, err)}
+			}
+		}
+	}
+
+	for _, r := range list {
+		if _, ok := m[r.(string)]; !ok {
+			return fmt.Errorf("missing %q", r)
+		}
+	}
+
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if names, ok := schema["propertyNames"]; ok {
+			if err := validate(root, names, k); err != nil {
+				return fmt.Errorf("property name %q: %v", k, err)
+			}
+		}
+
+		s, ok := properties[k]
+		if !ok {
+			if !hasAdditional {
+				continue
+			}
+			if closed {
+				return fmt.Errorf("unexpected %q", k)
+			}
+			s = additional
+		}
+		if err := validate(root, s, m[k]); err != nil {
+			return fmt.Errorf("%s: %v", k, err)
+		}
+	}
+
+	return nil
+}
+
+// checkSchema validates dumped, after a trip through encoding/json, against
+// the schema for the given format version.
+func checkSchema(version FormatVersion, dumped interface{}) error {
+	// Round-trip the schema too, so that it's checked in the form
+	// `goblin --schema` prints it.
+	var root map[string]interface{}
+	text, _ := json.Marshal(Schema(version))
+	json.Unmarshal(text, &root)
+
+	var value interface{}
+	text, _ = json.Marshal(dumped)
+	json.Unmarshal(text, &value)
+
+	return validate(root, root, value)
+}
+
+func TestFixturesMatchSchema(t *testing.T) {
+	fixtures, _ := filepath.Glob("testdata/*/*/*.json")
+	for _, p := range fixtures {
+		text, _ := ioutil.ReadFile(p)
+		var value interface{}
+		if err := json.Unmarshal(text, &value); err != nil {
+			t.Errorf("%s: %v", p, err)
+			continue
+		}
+		if err := checkSchema(DefaultFormat, value); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+
+	// dumpFixture checks each dump against the schema.
+	packages, _ := filepath.Glob("testdata/packages/*/*.go")
+	for _, p := range packages {
+		for _, opts := range []Options{{}, {Ranges: true}, {Positions: NoPositions}, {Shape: LegacyTypeAliases}, {Format: FormatV2}} {
+			dumpFixture(t, p, opts)
+		}
+	}
+}
+
+func TestSchemaRejectsMistakes(t *testing.T) {
+	for _, text := range []string{
+		`{"kind": "ident"}`,
+		`{"kind": "ident", "value": "x", "position": null, "extra": 1}`,
+		`{"kind": "statement", "type": "nonsense", "position": null}`,
+		`{"kind": "statement", "type": "if", "position": null, "init": null, "condition": null, "body": [], "else": 7}`,
+		`{"kind": "unary", "operator": "-", "position": null, "target": null}`,
+	} {
+		var value interface{}
+		json.Unmarshal([]byte(text), &value)
+		if checkSchema(FormatV2, value) == nil {
+			t.Errorf("Schema accepted %s", text)
+		}
+	}
+}
+
+// TestSchemaMatchesMetaSchema checks that Schema is a valid JSON Schema by
+// validating it against the draft 7 meta-schema, which is copied from
+// http://json-schema.org/draft-07/schema.
+func TestSchemaMatchesMetaSchema(t *testing.T) {
+	text, err := ioutil.ReadFile("testdata/draft-07-schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(text, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validate(meta, meta, meta); err != nil {
+		t.Fatalf("The meta-schema doesn't match itself: %v", err)
+	}
+
+	for _, version := range []FormatVersion{FormatV1, FormatV2} {
+		var schema interface{}
+		text, _ := json.Marshal(Schema(version))
+		json.Unmarshal(text, &schema)
+		if err := validate(meta, meta, schema); err != nil {
+			t.Errorf("Schema(%v) isn't a valid schema: %v", version, err)
+		}
+	}
+
+	for _, text := range []string{
+		`{"type": "nonsense"}`,
+		`{"type": "object", "required": "kind"}`,
+		`{"oneOf": []}`,
+		`{"properties": {"kind": 7}}`,
+	} {
+		var schema interface{}
+		json.Unmarshal([]byte(text), &schema)
+		if validate(meta, meta, schema) == nil {
+			t.Errorf("The meta-schema accepted %s", text)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+)
+
+// Snippets that aren't whole files have to be wrapped in a dummy file to be
+// parsed. The wrapping ends in a newline, so that the snippet starts at the
+// beginning of a line and a trailing line comment can't swallow the closing
+// brace.
+const (
+	stmtsPrefix = "package p; func _() {\n"
+	declsPrefix = "package p\n"
+)
+
+// parseSnippet parses src wrapped in prefix and suffix as a file called
+// "stdin", returning the position src starts at. Syntax errors are reported
+// relative to src.
+func parseSnippet(fset *token.FileSet, prefix, src, suffix string) (*ast.File, token.Pos, error) {
+	f, err := parser.ParseFile(fset, "stdin", prefix+src+suffix, parser.ParseComments)
+	if f == nil {
+		return nil, token.NoPos, err
+	}
+
+	origin := f.FileStart + token.Pos(len(prefix))
+	if list, ok := err.(scanner.ErrorList); ok {
+		start := fset.Position(origin)
+		for _, e := range list {
+			e.Pos = Relative(e.Pos, start)
+		}
+	}
+
+	return f, origin, err
+}
+
+// ParseStmts parses src as a list of statements, such as the body of a
+// function. Hand the position it returns to Dumper.SetOrigin to have positions
+// reported relative to src rather than to the dummy function it was parsed in.
+func ParseStmts(fset *token.FileSet, src string) ([]ast.Stmt, token.Pos, error) {
+	f, origin, err := parseSnippet(fset, stmtsPrefix, src, "\n}")
+	if err != nil {
+		return nil, origin, err
+	}
+
+	return f.Decls[0].(*ast.FuncDecl).Body.List, origin, nil
+}
+
+// DumpStmts dumps a list of statements, such as one from ParseStmts.
+func (d *Dumper) DumpStmts(stmts []ast.Stmt) (res []interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+
+	res = make([]interface{}, len(stmts))
+	for i, v := range stmts {
+		res[i] = d.dumpStmt(v)
+	}
+
+	return res, d.collected()
+}
+
+// ParseDecls parses src as one or more top-level declarations, as found in a
+// file after its package clause. Like ParseStmts, it returns the position to
+// hand to Dumper.SetOrigin.
+func ParseDecls(fset *token.FileSet, src string) ([]ast.Decl, token.Pos, error) {
+	f, origin, err := parseSnippet(fset, declsPrefix, src, "\n")
+	if err != nil {
+		return nil, origin, err
+	}
+
+	return f.Decls, origin, nil
+}
+
+// DumpDecls dumps a list of declarations, such as one from ParseDecls.
+func (d *Dumper) DumpDecls(decls []ast.Decl) (res []interface{}, err error) {
+	defer d.recover(&err)
+	d.begin()
+
+	res = make([]interface{}, len(decls))
+	for i, v := range decls {
+		res[i] = d.dumpDecl(v)
+	}
+
+	return res, d.collected()
+}
@@ -0,0 +1,76 @@
+package goblin
+
+import (
+	"encoding/json"
+	"go/scanner"
+	"go/token"
+	"testing"
+)
+
+func TestStmtsAreRelative(t *testing.T) {
+	fset := token.NewFileSet()
+	stmts, origin, err := ParseStmts(fset, "x := 1\nif x > 0 { y() } // trailing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDumper(fset, Options{})
+	d.SetOrigin(origin)
+	gotten, err := d.DumpStmts(stmts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gotten) != 2 {
+		t.Fatalf("Dumped %d statements, wanted 2", len(gotten))
+	}
+
+	wanted := []token.Position{
+		{Filename: "stdin", Line: 1, Column: 1, Offset: 0},
+		{Filename: "stdin", Line: 2, Column: 1, Offset: 7},
+	}
+	for i, v := range gotten {
+		if pos := v.(map[string]interface{})["position"]; !reflectEqualJSON(pos, DumpPosition(wanted[i])) {
+			t.Errorf("Statement %d is at %v, wanted %v", i, pos, wanted[i])
+		}
+	}
+}
+
+func TestStmtSyntaxErrorsAreRelative(t *testing.T) {
+	_, _, err := ParseStmts(token.NewFileSet(), "x := ")
+	list, ok := err.(scanner.ErrorList)
+	if !ok || list[0].Pos.Line != 2 || list[0].Pos.Offset != 6 {
+		t.Errorf("Syntax error not reported relative to the snippet: %v", err)
+	}
+}
+
+func TestBareStmt(t *testing.T) {
+	var gotten map[string]interface{}
+	json.Unmarshal(TestStmt("return 1"), &gotten)
+	if gotten["kind"] != "statement" || gotten["type"] != "return" {
+		t.Error("Didn't dump a bare statement")
+	}
+}
+
+func reflectEqualJSON(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+func TestBareDecls(t *testing.T) {
+	gotten := TestDecl("type T int\n\nfunc (T) M() {}")
+	if len(gotten) != 2 {
+		t.Fatalf("Dumped %d declarations, wanted 2", len(gotten))
+	}
+
+	method := gotten[1].(map[string]interface{})
+	if method["type"] != "method" {
+		t.Error("Didn't dump a method declaration")
+	}
+
+	wanted := DumpPosition(token.Position{Filename: "stdin", Line: 3, Column: 1, Offset: 12})
+	if !reflectEqualJSON(method["position"], wanted) {
+		t.Errorf("Method is at %v, wanted %v", method["position"], wanted)
+	}
+}
@@ -0,0 +1,12 @@
+package main
+
+import "time"
+
+type Duration = time.Duration
+
+type Timeout time.Duration
+
+type Set[T comparable] = map[T]struct{}
+
+func main() {
+}
@@ -0,0 +1,16 @@
+package main
+
+type List[T any] struct {
+	items []T
+}
+
+func (l *List[T]) Push(v T) {
+	l.items = append(l.items, v)
+}
+
+func (l List[T]) Len() int {
+	return len(l.items)
+}
+
+func main() {
+}
@@ -0,0 +1,11 @@
+package main
+
+type Pair[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+type Box[T interface{}] []T
+
+func main() {
+}
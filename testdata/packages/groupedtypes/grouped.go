@@ -0,0 +1,12 @@
+package main
+
+type (
+	Celsius    float64
+	Fahrenheit float64
+	Reading    struct {
+		value Celsius
+	}
+)
+
+func main() {
+}
@@ -0,0 +1,12 @@
+package main
+
+var cache Map[string, List[int]]
+
+func first(l List[int]) Pair[int, string] {
+	return Pair[int, string]{l.items[0], ""}
+}
+
+func main() {
+	fns := []func(int){}
+	fns[0](Sum[int](nil))
+}
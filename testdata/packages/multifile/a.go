@@ -0,0 +1,7 @@
+package multifile
+
+import "fmt"
+
+func A() {
+	fmt.Println(B())
+}
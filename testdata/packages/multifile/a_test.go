@@ -0,0 +1,7 @@
+package multifile
+
+import "testing"
+
+func TestA(t *testing.T) {
+	A()
+}
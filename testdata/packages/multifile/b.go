@@ -0,0 +1,10 @@
+package multifile
+
+import (
+	"fmt"
+	"strings"
+)
+
+func B() string {
+	return strings.ToUpper(fmt.Sprint("b"))
+}
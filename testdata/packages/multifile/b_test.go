@@ -0,0 +1,6 @@
+package multifile_test
+
+import "testing"
+
+func TestB(t *testing.T) {
+}
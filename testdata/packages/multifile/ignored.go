@@ -0,0 +1,9 @@
+//go:build ignore
+
+package multifile
+
+import "os"
+
+func Ignored() {
+	os.Exit(1)
+}
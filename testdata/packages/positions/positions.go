@@ -0,0 +1,19 @@
+package main
+
+func main() {
+	lengths := [...]int{1, 2, 3}
+	names := map[string]*int{"one": &lengths[0]}
+	ch := make(chan int)
+
+	select {
+	case v := <-ch:
+		*names["one"] = v
+	default:
+		println(len(names))
+	}
+
+	switch len(lengths) {
+	case 3:
+		println("three")
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "fmt"
+
+type Number interface {
+	~int | ~int64 | float64
+}
+
+type Stringish interface {
+	~string
+	fmt.Stringer
+	Len() int
+}
+
+func Sum[T ~int | ~float64](xs []T) T {
+	var total T
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func main() {
+}
@@ -0,0 +1,11 @@
+package main
+
+func main() {
+	var x interface{}
+	switch y := x.(type) {
+	case int, *int:
+		_ = y
+	case []int, map[string]int, *struct{}:
+	}
+	_ = x.(int)
+}
@@ -0,0 +1,138 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+)
+
+// Checked is what go/types found out about a package, for Options.Types.
+type Checked struct {
+	Package *types.Package
+	Info    *types.Info
+}
+
+// Check type-checks files, which make up the package imported as path.
+// Imports are type-checked from source, so nothing needs to have been
+// installed or downloaded beforehand. Type errors don't stop the check: they
+// are returned as an ErrorList of "type_error"s alongside whatever could be
+// worked out.
+func Check(fset *token.FileSet, path string, files []*ast.File) (*Checked, error) {
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+
+	var errs ErrorList
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if e, ok := err.(types.Error); ok {
+				errs = append(errs, &Error{Type: "type_error", Info: e.Msg, Position: e.Fset.Position(e.Pos)})
+			}
+		},
+	}
+
+	pkg, _ := conf.Check(path, fset, files, info)
+	checked := &Checked{Package: pkg, Info: info}
+	if len(errs) > 0 {
+		return checked, errs
+	}
+
+	return checked, nil
+}
+
+// object says what sort of thing an identifier, or a qualified identifier,
+// stands for.
+func (c *Checked) object(e ast.Expr) types.Object {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if obj := c.Info.Uses[e]; obj != nil {
+			return obj
+		}
+		return c.Info.Defs[e]
+
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			if _, ok := c.Info.Uses[x].(*types.PkgName); ok {
+				return c.Info.Uses[e.Sel]
+			}
+		}
+	}
+
+	return nil
+}
+
+// isType reports whether e denotes a type. known is false if we have no type
+// information about e, and have to guess.
+func (d *Dumper) isType(e ast.Expr) (isType, known bool) {
+	if d.opts.Types == nil {
+		return false, false
+	}
+
+	tv, ok := d.opts.Types.Info.Types[e]
+	return tv.IsType(), ok
+}
+
+// isPackage reports whether e is the name of an imported package, with known
+// as for isType.
+func (d *Dumper) isPackage(e ast.Expr) (isPackage, known bool) {
+	i, ok := e.(*ast.Ident)
+	if d.opts.Types == nil || !ok {
+		return false, d.opts.Types != nil && !ok
+	}
+
+	obj, ok := d.opts.Types.Info.Uses[i]
+	_, isPackage = obj.(*types.PkgName)
+	return isPackage, ok
+}
+
+// annotate adds a "types" object to m, the dump of e, holding e's type, its
+// value if it's a constant, and what it is if it's an identifier. Only the
+// last dump of e keeps it, as located explains, so an identifier expression
+// carries its types but the name inside it doesn't.
+func (d *Dumper) annotate(e ast.Expr, m map[string]interface{}) {
+	if previous := d.annotated[e]; previous != nil {
+		delete(previous, "types")
+	}
+	d.annotated[e] = m
+
+	checked := d.opts.Types
+	qualifier := types.RelativeTo(checked.Package)
+	res := map[string]interface{}{}
+
+	// Identifiers being declared have no entry in Types, so we fall back
+	// on the type of what they declare.
+	obj := checked.object(e)
+	tv, ok := checked.Info.Types[e]
+	t := tv.Type
+	if !ok && obj != nil {
+		t = obj.Type()
+	}
+	if t != nil && t != types.Typ[types.Invalid] {
+		res["type"] = types.TypeString(t, qualifier)
+	}
+	if tv.Value != nil {
+		res["value"] = tv.Value.ExactString()
+	}
+
+	switch obj.(type) {
+	case nil:
+	case *types.PkgName:
+		res["object"] = "package"
+	case *types.TypeName:
+		res["object"] = "type"
+	case *types.Builtin:
+		res["object"] = "builtin"
+	case *types.Label:
+		res["object"] = "label"
+	default:
+		res["object"] = "value"
+	}
+
+	if len(res) > 0 {
+		m["types"] = res
+	}
+}
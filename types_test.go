@@ -0,0 +1,170 @@
+package goblin
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const typedSource = `package p
+
+import "strings"
+
+type T int
+
+const c = 1 << 3
+
+func F(s string) int {
+	x := T(len(s)) + c
+	_ = strings.ToUpper(s)
+	return int(x)
+}
+`
+
+func TestTypes(t *testing.T) {
+	res := dumpSource(t, "typed.go", typedSource, 0, Options{Types: typeCheck})
+
+	types := func(n interface{}) map[string]interface{} {
+		m, _ := n.(map[string]interface{})["types"].(map[string]interface{})
+		return m
+	}
+
+	constant := res["declarations"].([]interface{})[2].(map[string]interface{})["specs"].([]interface{})[0].(map[string]interface{})
+	if v := types(constant["values"].([]interface{})[0]); v["type"] != "untyped int" || v["value"] != "8" {
+		t.Errorf("Annotated 1 << 3 with %v", v)
+	}
+
+	body := res["declarations"].([]interface{})[3].(map[string]interface{})["body"].([]interface{})
+	sum := body[0].(map[string]interface{})["right"].([]interface{})[0].(map[string]interface{})
+	if v := types(sum); v["type"] != "T" {
+		t.Errorf("Annotated T(len(s)) + c with %v", v)
+	}
+
+	call := sum["left"].(map[string]interface{})["target"].(map[string]interface{})
+	if v := types(call["function"]); v["object"] != "builtin" {
+		t.Errorf("Annotated len with %v", v)
+	}
+	if v := types(call["function"].(map[string]interface{})["value"]); v != nil {
+		t.Errorf("Annotated len twice, with %v inside the identifier expression", v)
+	}
+
+	upper := body[1].(map[string]interface{})["right"].([]interface{})[0].(map[string]interface{})
+	if v := types(upper["function"]); v["object"] != "value" || v["type"] != "func(s string) string" {
+		t.Errorf("Annotated strings.ToUpper with %v", v)
+	}
+
+	plain := dumpSource(t, "typed.go", typedSource, 0, Options{})
+	if types(plain["declarations"].([]interface{})[3].(map[string]interface{})["name"]) != nil {
+		t.Error("Annotated a dump without being asked to")
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, "typed.go", "package p\n\nvar x int = \"no\"\nvar y = x\n", 0)
+	checked, err := Check(fset, "p", []*ast.File{f})
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Type != "type_error" || errs[0].Position.Line != 3 {
+		t.Fatalf("Returned %v for a type error", err)
+	}
+
+	if checked == nil || len(checked.Info.Types) == 0 {
+		t.Error("Gave up on the rest of the package after a type error")
+	}
+}
+
+func TestTypedCalls(t *testing.T) {
+	src := `package p
+
+import "time"
+
+type T struct{}
+
+func (T) M() {}
+
+func F(x int64, t T, f func(*T), fs []func(*T)) {
+	_ = int32(x)
+	_ = time.Duration(x)
+	_ = time.Now()
+	_ = (*T)(nil)
+	t.M()
+	(f)(nil)
+	_ = G[int](1)
+	fs[0](nil)
+}
+
+func G[U any](u U) U { return u }
+`
+	dump := func(opts Options) []interface{} {
+		res := dumpSource(t, "calls.go", src, 0, opts)
+		return res["declarations"].([]interface{})[3].(map[string]interface{})["body"].([]interface{})
+	}
+	rhs := func(stmt interface{}) map[string]interface{} {
+		return stmt.(map[string]interface{})["right"].([]interface{})[0].(map[string]interface{})
+	}
+	call := func(stmt interface{}) map[string]interface{} {
+		return stmt.(map[string]interface{})["value"].(map[string]interface{})
+	}
+
+	body := dump(Options{Types: typeCheck})
+	if rhs(body[0])["type"] != "cast" {
+		t.Error("Didn't dump a conversion to a predeclared type as a cast")
+	}
+
+	duration := rhs(body[1])
+	if duration["type"] != "cast" || duration["coerced-to"].(map[string]interface{})["qualifier"] == nil {
+		t.Error("Didn't dump a conversion to a qualified type as a cast")
+	}
+
+	now := rhs(body[2])
+	if now["type"] != "call" || now["function"].(map[string]interface{})["qualifier"] == nil {
+		t.Error("Didn't dump a call to a qualified function as a call")
+	}
+
+	if rhs(body[3])["type"] != "cast" {
+		t.Error("Didn't dump a conversion to a pointer type as a cast")
+	}
+
+	if call(body[4])["function"].(map[string]interface{})["type"] != "selector" {
+		t.Error("Dumped a method value as a qualified identifier")
+	}
+
+	if call(body[5])["type"] != "call" {
+		t.Error("Dumped a call to a parenthesized function as a cast")
+	}
+
+	if rhs(body[6])["function"].(map[string]interface{})["type"] != "instantiation" {
+		t.Error("Didn't dump an explicit instantiation with one argument as an instantiation")
+	}
+
+	if call(body[7])["function"].(map[string]interface{})["type"] != "index" {
+		t.Error("Dumped indexing a slice of functions as an instantiation")
+	}
+
+	// Without types, we're left with the old guesses.
+	body = dump(Options{})
+	if rhs(body[0])["type"] != "call" || call(body[4])["function"].(map[string]interface{})["type"] != "identifier" {
+		t.Error("Didn't fall back on guessing without types")
+	}
+	if rhs(body[6])["function"].(map[string]interface{})["type"] != "index" {
+		t.Error("Didn't fall back on dumping a single-argument instantiation as an index")
+	}
+}
+
+func TestTypedReceiver(t *testing.T) {
+	src := "package p\n\ntype List[T any] []T\n\nfunc (l *List[T]) Len() int { return len(*l) }\n"
+	res := dumpSource(t, "receiver.go", src, 0, Options{Types: typeCheck, Ranges: true})
+
+	method := res["declarations"].([]interface{})[1].(map[string]interface{})
+	receiver := method["receiver"].(map[string]interface{})["declared-type"].(map[string]interface{})
+	if v, _ := receiver["types"].(map[string]interface{}); v["type"] != "*List[T]" {
+		t.Errorf("Annotated the receiver type with %v", v)
+	}
+	if end := receiver["range"].(map[string]interface{})["end"].(map[string]interface{}); end["column"] != float64(17) {
+		t.Errorf("Ended the receiver type at %v, not after its type arguments", end)
+	}
+	if params := method["type-params"].([]map[string]interface{}); len(params) != 1 {
+		t.Errorf("Dumped the method's type parameters as %v", params)
+	}
+}